@@ -1,22 +1,32 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
+	"text/tabwriter"
 
 	"github.com/ridzuwary/gokit-scaffold/internal/generator"
 	"github.com/ridzuwary/gokit-scaffold/internal/spec"
 	"github.com/ridzuwary/gokit-scaffold/internal/ui"
+	"github.com/ridzuwary/gokit-scaffold/templates"
 )
 
 const (
 	ToolName    = "gokit-scaffold"
-	ToolVersion = "0.1.0"
+	ToolVersion = spec.ToolVersion
 )
 
+// modulePrefixEnv names the environment variable holding the organisation's
+// module prefix (e.g. github.com/acme), used to suggest a module path.
+const modulePrefixEnv = "GOKIT_SCAFFOLD_MODULE_PREFIX"
+
 func main() {
 	os.Exit(run(os.Args[1:]))
 }
@@ -30,10 +40,18 @@ func run(args []string) int {
 	switch args[0] {
 	case "new":
 		return runNew(args[1:])
+	case "init":
+		return runInit(args[1:])
 	case "validate":
 		return runValidate(args[1:])
+	case "upgrade":
+		return runUpgrade(args[1:])
 	case "print":
 		return runPrint(args[1:])
+	case "packs":
+		return runPacks(args[1:])
+	case "render":
+		return runRender(args[1:])
 	case "-h", "--help", "help":
 		ui.PrintRootUsage(ToolName)
 		return 0
@@ -52,51 +70,473 @@ func runNew(args []string) int {
 	module := fs.String("module", "", "go module path (required)")
 	dir := fs.String("dir", "", "output directory (default ./<name>)")
 	httpPort := fs.Int("http-port", 8080, "HTTP listen port")
+	goVersion := fs.String("go-version", "", "Go version for the go directive in go.mod, such as 1.22 or 1.23.4 (default: the version this tool was built with)")
+	templateDir := fs.String("template-dir", "", "load the template pack from this directory instead of the embedded service-http pack")
+	vars := varFlag(fs)
+	features := featureFlags(fs)
+	dryRun := fs.Bool("dry-run", false, "print the files that would be created without writing anything")
+	showContent := fs.Bool("show-content", false, "with --dry-run, also print the rendered content of every file")
+	force := fs.Bool("force", false, "allow generating into a non-empty directory (or replacing an existing --output-archive)")
+	onConflict := fs.String("on-conflict", string(generator.ConflictFail), "with --force, what to do with existing files: skip|overwrite|backup|fail")
+	verify := fs.Bool("verify", false, "type-check the generated Go packages offline before writing anything")
+	specPath := fs.String("spec", "", "read the spec from a JSON file (or a .gokit-scaffold marker); flags override its values")
+	interactive := fs.Bool("interactive", false, "prompt for the name, module, port, pack and features (default when stdin is a terminal and --name or --module is missing)")
+	outputArchive := fs.String("output-archive", "", "write the project to this .tar.gz or .zip file instead of a directory")
 
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
-	if *name == "" || *module == "" {
+	passed := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		passed[f.Name] = true
+	})
+	var file spec.SpecFile
+	if *specPath != "" {
+		var err error
+		file, err = spec.ReadSpecFile(*specPath)
+		if err != nil {
+			ui.PrintError(err)
+			return 1
+		}
+		if err := applySpecFile(fs, file, vars); err != nil {
+			ui.PrintError(err)
+			return 1
+		}
+		if file.TemplatePack != "" && file.TemplatePack != spec.TemplatePackName && *templateDir == "" {
+			ui.PrintError(fmt.Errorf("%s was generated from template pack %s; pass its directory with --template-dir", *specPath, file.TemplatePack))
+			return 1
+		}
+	}
+	project := spec.ProjectSpec{
+		Name:        *name,
+		Module:      *module,
+		HTTPPort:    *httpPort,
+		GoVersion:   *goVersion,
+		Force:       *force,
+		TemplateDir: *templateDir,
+		Vars:        vars,
+		Features:    features,
+	}
+	if file.Features != nil {
+		pack, err := generator.ResolvePack(project)
+		if err != nil {
+			ui.PrintError(err)
+			return 1
+		}
+		project.Features = generator.ExactFeatures(pack, file.Features)
+		for feature, enabled := range features {
+			project.Features[feature] = enabled
+		}
+	}
+
+	if *interactive || ((project.Name == "" || project.Module == "") && ui.IsTerminal(os.Stdin)) {
+		prompter := ui.NewPrompter(os.Stdin, os.Stderr)
+		var err error
+		project, err = promptProject(prompter, project, os.Getenv(modulePrefixEnv))
+		if err != nil {
+			ui.PrintError(err)
+			return 1
+		}
+	}
+	if project.Name == "" || project.Module == "" {
 		ui.PrintError(fmt.Errorf("--name and --module are required"))
 		fs.Usage()
 		return 2
 	}
+	if *showContent && !*dryRun {
+		ui.PrintError(fmt.Errorf("--show-content requires --dry-run"))
+		return 2
+	}
+	policy, err := generator.ParseConflictPolicy(*onConflict)
+	if err != nil {
+		ui.PrintError(err)
+		return 2
+	}
+	if policy != generator.ConflictFail && !*force {
+		ui.PrintError(fmt.Errorf("--on-conflict requires --force"))
+		return 2
+	}
 
-	targetDir := *dir
-	if targetDir == "" {
-		targetDir = filepath.Join(".", *name)
+	if *outputArchive != "" {
+		var combined []string
+		for _, option := range []struct {
+			flag string
+			set  bool
+		}{{"dir", *dir != ""}, {"dry-run", *dryRun}, {"on-conflict", policy != generator.ConflictFail}} {
+			if option.set {
+				combined = append(combined, optionSource(option.flag, passed))
+			}
+		}
+		if len(combined) > 0 {
+			ui.PrintError(fmt.Errorf("%s cannot be combined with %s", optionSource("output-archive", passed), strings.Join(combined, " or ")))
+			return 2
+		}
+		if err := project.ValidateSettings(); err != nil {
+			ui.PrintError(err)
+			return 1
+		}
+		if err := generator.GenerateArchive(project, generator.Options{Version: ToolVersion, Verify: *verify}, *outputArchive); err != nil {
+			ui.PrintError(err)
+			return 1
+		}
+		ui.PrintNewSuccess(*outputArchive)
+		return 0
 	}
 
-	project := spec.ProjectSpec{
-		Name:     *name,
-		Module:   *module,
-		Dir:      targetDir,
-		HTTPPort: *httpPort,
+	project.Dir = *dir
+	if project.Dir == "" {
+		project.Dir = filepath.Join(".", project.Name)
 	}
 	if err := project.Validate(); err != nil {
 		ui.PrintError(err)
 		return 1
 	}
 
-	if err := generator.Generate(project, ToolVersion); err != nil {
+	if *dryRun {
+		plan, err := generator.Plan(project, ToolVersion)
+		if err != nil {
+			ui.PrintError(err)
+			return 1
+		}
+		if *verify {
+			if err := generator.Verify(plan); err != nil {
+				ui.PrintError(err)
+				return 1
+			}
+		}
+		report, err := generator.DryRun(plan, policy)
+		if err != nil {
+			ui.PrintError(err)
+			return 1
+		}
+		fmt.Fprintln(os.Stdout, formatDryRunOutput(plan, report, *showContent))
+		return 0
+	}
+
+	report, err := generator.Generate(project, generator.Options{
+		Version:    ToolVersion,
+		OnConflict: policy,
+		Verify:     *verify,
+	})
+	if err != nil {
 		ui.PrintError(err)
 		return 1
 	}
 
 	ui.PrintNewSuccess(project.Dir)
+	if *force {
+		fmt.Fprintln(os.Stdout, formatGenerateReport(report))
+	}
 	return 0
 }
 
+func varFlag(fs *flag.FlagSet) map[string]string {
+	vars := map[string]string{}
+	fs.Func("var", "set a template pack variable as name=value (repeatable)", func(value string) error {
+		name, val, ok := strings.Cut(value, "=")
+		if !ok || name == "" {
+			return fmt.Errorf("want name=value, got %q", value)
+		}
+		vars[name] = val
+		return nil
+	})
+	return vars
+}
+
+func featureFlags(fs *flag.FlagSet) map[string]bool {
+	features := map[string]bool{}
+	featureFlag := func(enabled bool) func(string) error {
+		return func(value string) error {
+			for _, name := range strings.Split(value, ",") {
+				name = strings.TrimSpace(name)
+				if name == "" {
+					continue
+				}
+				if prev, ok := features[name]; ok && prev != enabled {
+					return fmt.Errorf("feature %q passed to both --with and --without", name)
+				}
+				features[name] = enabled
+			}
+			return nil
+		}
+	}
+	fs.Func("with", "comma-separated optional features to enable (e.g. metrics,pprof,docker)", featureFlag(true))
+	fs.Func("without", "comma-separated features to disable", featureFlag(false))
+	return features
+}
+
+func runInit(args []string) int {
+	fs := flag.NewFlagSet("init", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	dir := fs.String("dir", ".", "existing Go module to add the scaffold to")
+	name := fs.String("name", "", "project name (default: derived from the module path)")
+	httpPort := fs.Int("http-port", 8080, "HTTP listen port")
+	goVersion := fs.String("go-version", "", "Go version recorded for the scaffold (default: the go directive of go.mod)")
+	templateDir := fs.String("template-dir", "", "load the template pack from this directory instead of the embedded service-http pack")
+	vars := varFlag(fs)
+	features := featureFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	module, modGoVersion, err := generator.ReadGoMod(*dir)
+	if err != nil {
+		ui.PrintError(err)
+		return 1
+	}
+	if *name == "" {
+		*name = generator.NameFromModule(module)
+	}
+	if *goVersion == "" {
+		*goVersion = modGoVersion
+	}
+
+	project := spec.ProjectSpec{
+		Name:        *name,
+		Module:      module,
+		Dir:         *dir,
+		HTTPPort:    *httpPort,
+		GoVersion:   *goVersion,
+		Force:       true,
+		TemplateDir: *templateDir,
+		Vars:        vars,
+		Features:    features,
+
+		ExistingModule: true,
+	}
+	if err := project.Validate(); err != nil {
+		ui.PrintError(err)
+		return 1
+	}
+
+	report, err := generator.Init(project, generator.Options{Version: ToolVersion})
+	if err != nil {
+		ui.PrintError(err)
+		return 1
+	}
+
+	ui.PrintInfo(fmt.Sprintf("Scaffold initialised in %s for module %s", project.Dir, module))
+	fmt.Fprintln(os.Stdout, formatGenerateReport(report))
+	return 0
+}
+
+// promptProject asks for the settings of a new project, suggesting the values
+// already known from flags or a spec file.
+func promptProject(p *ui.Prompter, project spec.ProjectSpec, modulePrefix string) (spec.ProjectSpec, error) {
+	name, err := p.Ask("Project name", project.Name, spec.ValidateName)
+	if err != nil {
+		return project, err
+	}
+	if name != project.Name && project.Module != "" && path.Base(project.Module) == project.Name {
+		project.Module = path.Join(path.Dir(project.Module), name)
+	}
+	project.Name = name
+
+	suggested := project.Module
+	if suggested == "" && modulePrefix != "" {
+		suggested = strings.TrimSuffix(modulePrefix, "/") + "/" + name
+	}
+	if project.Module, err = p.Ask("Go module path", suggested, spec.ValidateModule); err != nil {
+		return project, err
+	}
+
+	port, err := p.Ask("HTTP port", strconv.Itoa(project.HTTPPort), func(answer string) error {
+		n, err := strconv.Atoi(answer)
+		if err != nil {
+			return errors.New("must be a number")
+		}
+		return spec.ValidateHTTPPort(n)
+	})
+	if err != nil {
+		return project, err
+	}
+	project.HTTPPort, _ = strconv.Atoi(port)
+
+	suggestedPack := spec.TemplatePackName
+	if project.TemplateDir != "" {
+		suggestedPack = project.TemplateDir
+	}
+	var pack generator.Pack
+	packAnswer, err := p.Ask(fmt.Sprintf("Template pack (%s, or a pack directory)", strings.Join(generator.TemplatePacks(), ", ")), suggestedPack, func(answer string) error {
+		var loadErr error
+		if answer == spec.TemplatePackName {
+			pack, loadErr = generator.EmbeddedPack(answer)
+		} else {
+			pack, loadErr = generator.LoadPackDir(answer)
+		}
+		return loadErr
+	})
+	if err != nil {
+		return project, err
+	}
+	project.TemplateDir = ""
+	if packAnswer != spec.TemplatePackName {
+		project.TemplateDir = packAnswer
+	}
+
+	if len(pack.Features) == 0 {
+		return project, nil
+	}
+	declared := map[string]bool{}
+	var enabled []string
+	p.Say("Features of %s:", pack.Name)
+	for _, feature := range pack.Features {
+		declared[feature.Name] = true
+		p.Say("  %-10s %s", feature.Name, feature.Description)
+		on := feature.Default
+		if choice, ok := project.Features[feature.Name]; ok {
+			on = choice
+		}
+		if on {
+			enabled = append(enabled, feature.Name)
+		}
+	}
+	chosen, err := p.AskList("Features to enable (comma-separated, - for none)", enabled, func(answer string) error {
+		if !declared[answer] {
+			return fmt.Errorf("is not a feature of %s", pack.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		return project, err
+	}
+	project.Features = generator.ExactFeatures(pack, chosen)
+	return project, nil
+}
+
+// optionSource names a `new` option the way the user set it: as a flag if it
+// was passed, otherwise as the spec file key it came from.
+func optionSource(flagName string, passed map[string]bool) string {
+	if passed[flagName] {
+		return "--" + flagName
+	}
+	return "spec file key " + strings.ReplaceAll(flagName, "-", "_")
+}
+
+// applySpecFile sets every flag the user did not pass from the spec file. Pack
+// variables are merged per name, with --var taking precedence.
+func applySpecFile(fs *flag.FlagSet, file spec.SpecFile, vars map[string]string) error {
+	set := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		set[f.Name] = true
+	})
+
+	values := map[string]string{
+		"name":           file.Name,
+		"module":         file.Module,
+		"dir":            file.Dir,
+		"output-archive": file.OutputArchive,
+		"go-version":     file.GoVersion,
+		"template-dir":   file.TemplateDir,
+		"on-conflict":    file.OnConflict,
+	}
+	if file.HTTPPort != 0 {
+		values["http-port"] = strconv.Itoa(file.HTTPPort)
+	}
+	for flagName, enabled := range map[string]bool{
+		"force":        file.Force,
+		"verify":       file.Verify,
+		"dry-run":      file.DryRun,
+		"show-content": file.ShowContent,
+	} {
+		if enabled {
+			values[flagName] = "true"
+		}
+	}
+	for flagName, value := range values {
+		if value == "" || set[flagName] {
+			continue
+		}
+		if err := fs.Set(flagName, value); err != nil {
+			return fmt.Errorf("spec file: %s: %w", flagName, err)
+		}
+	}
+
+	for name, value := range file.Vars {
+		if _, ok := vars[name]; !ok {
+			vars[name] = value
+		}
+	}
+	return nil
+}
+
+func formatGenerateReport(report generator.Report) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("%d created, %d skipped, %d overwritten, %d backed up\n",
+		report.Count(generator.ActionCreated),
+		report.Count(generator.ActionSkipped),
+		report.Count(generator.ActionOverwritten),
+		report.Count(generator.ActionBackedUp),
+	))
+
+	width := 0
+	for _, file := range report.Files {
+		if w := len(file.Action); w > width {
+			width = w
+		}
+	}
+	for _, file := range report.Files {
+		b.WriteString(fmt.Sprintf("  %-*s  %s", width, file.Action, file.OutputPath))
+		if file.BackupPath != "" {
+			b.WriteString(fmt.Sprintf(" (previous version saved as %s)", file.BackupPath))
+		}
+		b.WriteString("\n")
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func formatDryRunOutput(plan generator.RenderPlan, report generator.Report, showContent bool) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Dry run: %d files from template pack %s for %s (nothing written)\n", len(plan.Files), plan.TemplatePack, plan.Dir))
+	b.WriteString(formatGenerateReport(report))
+	b.WriteString("\n\n")
+
+	sizeWidth := 0
+	for _, file := range plan.Files {
+		if w := len(fmt.Sprint(file.Size())); w > sizeWidth {
+			sizeWidth = w
+		}
+	}
+	for _, file := range plan.Files {
+		b.WriteString(fmt.Sprintf("%*d B  sha256:%s  %s\n", sizeWidth, file.Size(), file.SHA256(), file.OutputPath))
+	}
+
+	if showContent {
+		for _, file := range plan.Files {
+			b.WriteString(fmt.Sprintf("\n--- %s (%d bytes)\n", file.OutputPath, file.Size()))
+			b.Write(file.Content)
+			if len(file.Content) > 0 && file.Content[len(file.Content)-1] != '\n' {
+				b.WriteString("\n")
+			}
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
 func runValidate(args []string) int {
 	fs := flag.NewFlagSet("validate", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
 	dir := fs.String("dir", ".", "directory to validate")
+	typecheck := fs.Bool("typecheck", false, "also type-check the scaffold's Go packages offline")
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
 
 	errs := spec.ValidateScaffoldDir(*dir)
+	// Drift is reported even for an invalid scaffold, so that a deleted
+	// required file shows up among the others; without a marker there is none.
+	drift, err := spec.ScaffoldDrift(*dir)
+	if err != nil && len(errs) == 0 {
+		ui.PrintError(err)
+		return 1
+	}
 	if len(errs) > 0 {
+		if len(drift) > 0 {
+			fmt.Fprintln(os.Stdout, formatDriftReport(drift))
+		}
 		ui.PrintError(fmt.Errorf("validation failed for %s", *dir))
 		for _, err := range errs {
 			fmt.Fprintf(os.Stderr, "  - %s\n", strings.TrimSpace(err.Error()))
@@ -105,17 +545,321 @@ func runValidate(args []string) int {
 	}
 
 	ui.PrintInfo(fmt.Sprintf("scaffold is valid: %s", *dir))
+	if len(drift) > 0 {
+		fmt.Fprintln(os.Stdout, formatDriftReport(drift))
+	}
+
+	if *typecheck {
+		typeErrs, err := generator.TypeCheckDir(*dir)
+		if err != nil {
+			ui.PrintError(err)
+			return 1
+		}
+		if len(typeErrs) > 0 {
+			ui.PrintError(fmt.Errorf("type-check failed for %s", *dir))
+			for _, typeErr := range typeErrs {
+				fmt.Fprintf(os.Stderr, "  - %s\n", typeErr)
+			}
+			return 1
+		}
+		ui.PrintInfo(fmt.Sprintf("type-check passed: %s", *dir))
+	}
 	return 0
 }
 
+func formatDriftReport(drift []spec.FileDrift) string {
+	counts := map[spec.FileState]int{}
+	width := 0
+	for _, file := range drift {
+		counts[file.State]++
+		if w := len(file.State); w > width {
+			width = w
+		}
+	}
+
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Generated files: %d untouched, %d modified, %d deleted\n",
+		counts[spec.FileUntouched], counts[spec.FileModified], counts[spec.FileDeleted]))
+	for _, file := range drift {
+		b.WriteString(fmt.Sprintf("  %-*s  %s\n", width, file.State, file.Path))
+	}
+	for _, file := range drift {
+		if file.ModeChanged() {
+			b.WriteString(fmt.Sprintf("warning: %s has mode %s, generated as %s\n",
+				file.Path, spec.FormatFileMode(file.Mode), spec.FormatFileMode(file.WantMode)))
+		}
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func runUpgrade(args []string) int {
+	fs := flag.NewFlagSet("upgrade", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	dir := fs.String("dir", ".", "scaffold directory to upgrade")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	report, err := generator.Upgrade(*dir, ToolVersion)
+	if err != nil {
+		ui.PrintError(err)
+		return 1
+	}
+
+	fmt.Fprintln(os.Stdout, formatUpgradeReport(report))
+	if conflicts := report.Conflicts(); len(conflicts) > 0 {
+		ui.PrintError(fmt.Errorf("upgrade left conflict markers in %d files; resolve them and commit: %s", len(conflicts), strings.Join(conflicts, ", ")))
+		return 1
+	}
+	return 0
+}
+
+func formatUpgradeReport(report generator.UpgradeReport) string {
+	var b strings.Builder
+	b.WriteString(fmt.Sprintf("Upgraded %s from %s to %s\n", report.Dir, report.FromVersion, report.ToVersion))
+
+	width := 0
+	for _, file := range report.Files {
+		if w := len(file.Status); w > width {
+			width = w
+		}
+	}
+	for _, file := range report.Files {
+		b.WriteString(fmt.Sprintf("  %-*s  %s\n", width, file.Status, file.OutputPath))
+	}
+
+	return strings.TrimRight(b.String(), "\n")
+}
+
+func runRender(args []string) int {
+	fs := flag.NewFlagSet("render", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+
+	packRef := fs.String("pack", spec.TemplatePackName, "template pack: an embedded pack name or a pack directory")
+	file := fs.String("file", "", "output path to render, as generated (e.g. internal/httpserver/server.go) (required)")
+	name := fs.String("name", "", "project name (required)")
+	module := fs.String("module", "", "go module path (required)")
+	httpPort := fs.Int("http-port", 8080, "HTTP listen port")
+	goVersion := fs.String("go-version", "", "Go version for the go directive in go.mod (default: the version this tool was built with)")
+	vars := varFlag(fs)
+	features := featureFlags(fs)
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if *file == "" || *name == "" || *module == "" {
+		ui.PrintError(errors.New("--file, --name and --module are required"))
+		return 2
+	}
+
+	project := spec.ProjectSpec{
+		Name:      *name,
+		Module:    *module,
+		HTTPPort:  *httpPort,
+		GoVersion: *goVersion,
+		Vars:      vars,
+		Features:  features,
+	}
+	if err := project.ValidateSettings(); err != nil {
+		ui.PrintError(err)
+		return 1
+	}
+	pack, err := generator.LoadPack(*packRef, "")
+	if err != nil {
+		ui.PrintError(err)
+		return 1
+	}
+
+	rendered, err := generator.RenderFile(pack, project, *file, ToolVersion)
+	if err != nil {
+		ui.PrintError(err)
+		return 1
+	}
+	if _, err := os.Stdout.Write(rendered.Content); err != nil {
+		ui.PrintError(fmt.Errorf("write output: %w", err))
+		return 1
+	}
+	return 0
+}
+
+func runPacks(args []string) int {
+	if len(args) == 0 {
+		ui.PrintPacksUsage(ToolName)
+		return 2
+	}
+
+	switch args[0] {
+	case "list":
+		return runPacksList(args[1:])
+	case "show":
+		return runPacksShow(args[1:])
+	case "-h", "--help", "help":
+		ui.PrintPacksUsage(ToolName)
+		return 0
+	default:
+		ui.PrintError(fmt.Errorf("unknown packs command: %s", args[0]))
+		ui.PrintPacksUsage(ToolName)
+		return 2
+	}
+}
+
+func runPacksList(args []string) int {
+	fs := flag.NewFlagSet("packs list", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	dir := fs.String("dir", "", "also list the template packs in the subdirectories of this directory")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+
+	packs, err := generator.ListPacks(*dir)
+	if err != nil {
+		ui.PrintError(err)
+		return 1
+	}
+	fmt.Fprintln(os.Stdout, formatPacksList(packs))
+	return 0
+}
+
+func runPacksShow(args []string) int {
+	fs := flag.NewFlagSet("packs show", flag.ContinueOnError)
+	fs.SetOutput(os.Stderr)
+	dir := fs.String("dir", "", "look up pack names in the subdirectories of this directory as well")
+	if err := fs.Parse(args); err != nil {
+		return 2
+	}
+	if fs.NArg() != 1 {
+		ui.PrintError(errors.New("packs show takes one pack name or pack directory"))
+		return 2
+	}
+
+	pack, err := generator.LoadPack(fs.Arg(0), *dir)
+	if err != nil {
+		ui.PrintError(err)
+		return 1
+	}
+	out, err := formatPackShow(pack)
+	if err != nil {
+		ui.PrintError(err)
+		return 1
+	}
+	fmt.Fprintln(os.Stdout, out)
+	return 0
+}
+
+func formatPacksList(packs []generator.Pack) string {
+	var b strings.Builder
+	w := tabwriter.NewWriter(&b, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "NAME\tVERSION\tORIGIN\tDESCRIPTION")
+	for _, pack := range packs {
+		version := pack.Version
+		if version == "" {
+			version = "-"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", pack.Name, version, pack.Origin, pack.Description)
+	}
+	_ = w.Flush()
+	return strings.TrimRight(b.String(), "\n")
+}
+
+// formatPackShow describes a pack's knobs. The tree lists every entry, with
+// all features enabled and output paths as written in the manifest.
+func formatPackShow(pack generator.Pack) (string, error) {
+	var b strings.Builder
+	b.WriteString(pack.Name)
+	if pack.Version != "" {
+		b.WriteString(" " + pack.Version)
+	}
+	b.WriteString("\n")
+	if pack.Description != "" {
+		b.WriteString(pack.Description + "\n")
+	}
+	b.WriteString(fmt.Sprintf("Origin: %s\n", pack.Origin))
+	if len(pack.Layers) > 1 {
+		b.WriteString(fmt.Sprintf("Layers: %s\n", strings.Join(pack.Layers, " -> ")))
+	}
+
+	b.WriteString("\nVariables\n")
+	if len(pack.Variables) == 0 {
+		b.WriteString("(none)\n")
+	}
+	for _, variable := range pack.Variables {
+		def, err := variable.DefaultString()
+		if err != nil {
+			return "", err
+		}
+		if variable.Default == nil {
+			def = "required"
+		} else {
+			def = "default " + def
+		}
+		b.WriteString(fmt.Sprintf("- %s (%s, %s)", variable.Name, variable.Type, def))
+		if variable.Description != "" {
+			b.WriteString(": " + variable.Description)
+		}
+		b.WriteString("\n")
+	}
+
+	b.WriteString("\nFeatures\n")
+	if len(pack.Features) == 0 {
+		b.WriteString("(none)\n")
+	}
+	outputs := pack.FeatureOutputs()
+	for _, feature := range pack.Features {
+		state := "off"
+		if feature.Default {
+			state = "on"
+		}
+		b.WriteString(fmt.Sprintf("- %s (default %s)", feature.Name, state))
+		if feature.Description != "" {
+			b.WriteString(": " + feature.Description)
+		}
+		b.WriteString("\n")
+		if files := outputs[feature.Name]; len(files) > 0 {
+			b.WriteString(fmt.Sprintf("  adds %s\n", strings.Join(files, ", ")))
+		}
+	}
+
+	paths := []string{spec.MarkerFileName}
+	for _, entry := range pack.Entries {
+		paths = append(paths, entry.OutputPath)
+	}
+	b.WriteString("\nGenerated Outputs Tree (all features)\n")
+	b.WriteString(generator.BuildASCIITree(paths))
+
+	return b.String(), nil
+}
+
 func runPrint(args []string) int {
 	fs := flag.NewFlagSet("print", flag.ContinueOnError)
 	fs.SetOutput(os.Stderr)
+	name := fs.String("name", "hello-api", "project name used to resolve templated output paths")
+	module := fs.String("module", "github.com/acme/hello-api", "go module path used to resolve templated output paths")
+	packRef := fs.String("pack", spec.TemplatePackName, "template pack to describe: an embedded pack name or a pack directory")
+	format := fs.String("format", "text", "output format: text|json")
 	if err := fs.Parse(args); err != nil {
 		return 2
 	}
+	if *format != "text" && *format != "json" {
+		ui.PrintError(fmt.Errorf("invalid --format %q (want text or json)", *format))
+		return 2
+	}
 
-	out, err := formatPrintOutput(ToolName, ToolVersion)
+	pack, err := generator.LoadPack(*packRef, "")
+	if err != nil {
+		ui.PrintError(err)
+		return 1
+	}
+	project := spec.ProjectSpec{
+		Name:     *name,
+		Module:   *module,
+		HTTPPort: 8080,
+	}
+	var out string
+	if *format == "json" {
+		out, err = formatPrintJSON(ToolName, ToolVersion, pack, project)
+	} else {
+		out, err = formatPrintOutput(ToolName, ToolVersion, pack, project)
+	}
 	if err != nil {
 		ui.PrintError(err)
 		return 1
@@ -125,9 +869,9 @@ func runPrint(args []string) int {
 	return 0
 }
 
-func formatPrintOutput(toolName, toolVersion string) (string, error) {
+func formatPrintOutput(toolName, toolVersion string, pack generator.Pack, project spec.ProjectSpec) (string, error) {
 	packs := generator.TemplatePacks()
-	tree, err := generator.OutputTree(generator.ServiceHTTPTemplatePack)
+	tree, err := generator.OutputTree(pack, project)
 	if err != nil {
 		return "", err
 	}
@@ -141,17 +885,95 @@ func formatPrintOutput(toolName, toolVersion string) (string, error) {
 		b.WriteString("\n")
 	}
 
-	b.WriteString("\nGenerated Outputs Tree (service-http)\n")
+	b.WriteString(fmt.Sprintf("\nGenerated Outputs Tree (%s)\n", pack.Name))
 	b.WriteString(tree)
 	b.WriteString("\n\nMarker Schema Summary\n")
-	b.WriteString("- `tool`: scaffold generator identifier (`gokit-scaffold`)\n")
-	b.WriteString("- `version`: tool version used to generate the scaffold\n")
-	b.WriteString("- `template_pack`: template pack name (`service-http`)\n")
-	b.WriteString("- `spec.name`: service name (`^[a-z][a-z0-9-]*$`)\n")
-	b.WriteString("- `spec.module`: Go module path\n")
-	b.WriteString("- `spec.http_port`: HTTP listen port (1-65535)\n")
+	for _, field := range spec.MarkerSchema() {
+		b.WriteString(fmt.Sprintf("- `%s`: %s\n", field.Path, field.Description))
+	}
 	b.WriteString("\nExample new command\n")
-	b.WriteString("gokit-scaffold new --name hello-api --module github.com/acme/hello-api --http-port 8080\n")
+	b.WriteString(fmt.Sprintf("gokit-scaffold new --name %s --module %s --http-port 8080", project.Name, project.Module))
+	if pack.Origin != generator.EmbeddedOrigin {
+		b.WriteString(" --template-dir " + pack.Origin)
+	}
+	b.WriteString("\n")
 
 	return strings.TrimRight(b.String(), "\n"), nil
 }
+
+type printDocument struct {
+	Tool         string             `json:"tool"`
+	Version      string             `json:"version"`
+	Packs        []string           `json:"packs"`
+	Pack         printPack          `json:"pack"`
+	Outputs      []string           `json:"outputs"`
+	Tree         string             `json:"tree"`
+	MarkerSchema []spec.SchemaField `json:"marker_schema"`
+}
+
+type printPack struct {
+	Name        string                   `json:"name"`
+	Description string                   `json:"description,omitempty"`
+	Version     string                   `json:"version,omitempty"`
+	Origin      string                   `json:"origin"`
+	Layers      []string                 `json:"layers"`
+	Entries     []printEntry             `json:"entries"`
+	Variables   []templates.PackVariable `json:"variables"`
+	Features    []templates.PackFeature  `json:"features"`
+}
+
+type printEntry struct {
+	Template string   `json:"template"`
+	Output   string   `json:"output"`
+	When     string   `json:"when,omitempty"`
+	Mode     string   `json:"mode"`
+	Copy     bool     `json:"copy,omitempty"`
+	Delims   []string `json:"delims,omitempty"`
+}
+
+// formatPrintJSON is the machine-readable form of print. Outputs and tree are
+// resolved for project with the pack's default features.
+func formatPrintJSON(toolName, toolVersion string, pack generator.Pack, project spec.ProjectSpec) (string, error) {
+	outputs, err := generator.OutputPaths(pack, project)
+	if err != nil {
+		return "", err
+	}
+
+	doc := printDocument{
+		Tool:    toolName,
+		Version: toolVersion,
+		Packs:   generator.TemplatePacks(),
+		Pack: printPack{
+			Name:        pack.Name,
+			Description: pack.Description,
+			Version:     pack.Version,
+			Origin:      pack.Origin,
+			Layers:      pack.Layers,
+			Entries:     make([]printEntry, 0, len(pack.Entries)),
+			Variables:   append([]templates.PackVariable{}, pack.Variables...),
+			Features:    append([]templates.PackFeature{}, pack.Features...),
+		},
+		Outputs:      outputs,
+		Tree:         generator.BuildASCIITree(outputs),
+		MarkerSchema: spec.MarkerSchema(),
+	}
+	for _, entry := range pack.Entries {
+		printed := printEntry{
+			Template: entry.TemplatePath,
+			Output:   entry.OutputPath,
+			When:     entry.When,
+			Mode:     spec.FormatFileMode(entry.Mode),
+			Copy:     entry.Copy,
+		}
+		if entry.Delims != [2]string{} {
+			printed.Delims = entry.Delims[:]
+		}
+		doc.Pack.Entries = append(doc.Pack.Entries, printed)
+	}
+
+	content, err := json.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return "", fmt.Errorf("encode print output: %w", err)
+	}
+	return string(content), nil
+}
@@ -1,8 +1,19 @@
 package main
 
 import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
+
+	"github.com/ridzuwary/gokit-scaffold/internal/generator"
+	"github.com/ridzuwary/gokit-scaffold/internal/spec"
+	"github.com/ridzuwary/gokit-scaffold/internal/ui"
 )
 
 func TestRunValidateMissingMarkerFails(t *testing.T) {
@@ -15,13 +26,17 @@ func TestRunValidateMissingMarkerFails(t *testing.T) {
 }
 
 func TestFormatPrintOutputContainsRequiredSections(t *testing.T) {
-	out, err := formatPrintOutput(ToolName, ToolVersion)
+	pack, err := generator.EmbeddedPack(spec.TemplatePackName)
+	if err != nil {
+		t.Fatalf("load pack: %v", err)
+	}
+	out, err := formatPrintOutput(ToolName, ToolVersion, pack, spec.ProjectSpec{Name: "hello-api", Module: "github.com/acme/hello-api", HTTPPort: 8080})
 	if err != nil {
 		t.Fatalf("formatPrintOutput returned error: %v", err)
 	}
 
 	required := []string{
-		"gokit-scaffold 0.1.0",
+		"gokit-scaffold 0.2.0",
 		"Template Packs",
 		"- service-http",
 		"Generated Outputs Tree (service-http)",
@@ -32,6 +47,7 @@ func TestFormatPrintOutputContainsRequiredSections(t *testing.T) {
 		"`tool`",
 		"`template_pack`",
 		"`spec.http_port`",
+		"`spec.go_version`",
 		"Example new command",
 		"gokit-scaffold new --name hello-api --module github.com/acme/hello-api --http-port 8080",
 	}
@@ -42,3 +58,269 @@ func TestFormatPrintOutputContainsRequiredSections(t *testing.T) {
 		}
 	}
 }
+
+func TestFormatPrintJSON(t *testing.T) {
+	pack, err := generator.EmbeddedPack(spec.TemplatePackName)
+	if err != nil {
+		t.Fatalf("load pack: %v", err)
+	}
+	out, err := formatPrintJSON(ToolName, ToolVersion, pack, spec.ProjectSpec{Name: "hello-api", Module: "github.com/acme/hello-api", HTTPPort: 8080})
+	if err != nil {
+		t.Fatalf("formatPrintJSON returned error: %v", err)
+	}
+
+	var doc printDocument
+	if err := json.Unmarshal([]byte(out), &doc); err != nil {
+		t.Fatalf("output is not JSON: %v\n%s", err, out)
+	}
+	if doc.Tool != ToolName || doc.Version != ToolVersion || doc.Pack.Name != spec.TemplatePackName {
+		t.Fatalf("unexpected header: %+v", doc)
+	}
+	if len(doc.Pack.Entries) != len(pack.Entries) || len(doc.Pack.Variables) == 0 || len(doc.Pack.Features) == 0 {
+		t.Fatalf("pack details missing: %+v", doc.Pack)
+	}
+	if !reflect.DeepEqual(doc.MarkerSchema, spec.MarkerSchema()) {
+		t.Fatalf("marker schema = %+v, want spec.MarkerSchema()", doc.MarkerSchema)
+	}
+	found := false
+	for _, output := range doc.Outputs {
+		found = found || output == "go.mod"
+	}
+	if !found || !strings.Contains(doc.Tree, "go.mod") {
+		t.Fatalf("outputs do not include go.mod: %v\n%s", doc.Outputs, doc.Tree)
+	}
+}
+
+func TestFormatPackShowDescribesKnobs(t *testing.T) {
+	pack, err := generator.LoadPack("service-http", "")
+	if err != nil {
+		t.Fatalf("load pack: %v", err)
+	}
+	out, err := formatPackShow(pack)
+	if err != nil {
+		t.Fatalf("formatPackShow returned error: %v", err)
+	}
+
+	required := []string{
+		"service-http 0.2.0",
+		"Origin: embedded",
+		"- shutdown_timeout_seconds (int, default 5): seconds",
+		"- metrics (default off): expvar",
+		"  adds internal/httpserver/metrics.go",
+		"Generated Outputs Tree (all features)",
+		"|-- Dockerfile",
+	}
+	for _, item := range required {
+		if !strings.Contains(out, item) {
+			t.Fatalf("expected output to contain %q, got:\n%s", item, out)
+		}
+	}
+
+	list := formatPacksList([]generator.Pack{pack})
+	if !strings.HasPrefix(list, "NAME") || !strings.Contains(list, "service-http  0.2.0    embedded") {
+		t.Fatalf("unexpected pack list:\n%s", list)
+	}
+}
+
+func TestRunPacksShowUnknownPackFails(t *testing.T) {
+	if code := run([]string{"packs", "show", "service-grpc"}); code != 1 {
+		t.Fatalf("expected exit code 1, got %d", code)
+	}
+	if code := run([]string{"packs", "show"}); code != 2 {
+		t.Fatalf("expected exit code 2 without a pack, got %d", code)
+	}
+}
+
+func TestRunRenderRequiresFileNameAndModule(t *testing.T) {
+	if code := run([]string{"render", "--name", "hello-api", "--module", "github.com/acme/hello-api"}); code != 2 {
+		t.Fatalf("expected exit code 2 without --file, got %d", code)
+	}
+}
+
+func TestRunNewDryRunWritesNothing(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hello-api")
+
+	code := run([]string{"new", "--name", "hello-api", "--module", "github.com/acme/hello-api", "--dir", dir, "--dry-run"})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("expected %s to not exist after dry run, stat err: %v", dir, err)
+	}
+}
+
+func TestRunNewOutputArchive(t *testing.T) {
+	root := t.TempDir()
+	archive := filepath.Join(root, "hello-api.tar.gz")
+
+	code := run([]string{"new", "--name", "hello-api", "--module", "github.com/acme/hello-api", "--go-version", "1.22", "--output-archive", archive, "--dir", filepath.Join(root, "hello-api")})
+	if code != 2 {
+		t.Fatalf("expected exit code 2 for --output-archive with --dir, got %d", code)
+	}
+
+	code = run([]string{"new", "--name", "hello-api", "--module", "github.com/acme/hello-api", "--go-version", "1.22", "--output-archive", archive})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatalf("read %s: %v", root, err)
+	}
+	if len(entries) != 1 || entries[0].Name() != "hello-api.tar.gz" {
+		t.Fatalf("expected only the archive to be written, found %d entries", len(entries))
+	}
+}
+
+func TestRunNewOutputArchiveFromSpecFile(t *testing.T) {
+	root := t.TempDir()
+	specPath := filepath.Join(root, "service.json")
+	content := `{"name": "hello-api", "module": "github.com/acme/hello-api", "go_version": "1.22", "dir": "hello-api"}`
+	if err := os.WriteFile(specPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write spec file: %v", err)
+	}
+	if code := run([]string{"new", "--spec", specPath, "--output-archive", filepath.Join(root, "hello-api.zip")}); code != 2 {
+		t.Fatalf("expected exit code 2 for --output-archive with a spec file dir, got %d", code)
+	}
+	if got := optionSource("dir", map[string]bool{"output-archive": true}); got != "spec file key dir" {
+		t.Fatalf("optionSource = %q", got)
+	}
+
+	archive := filepath.Join(root, "hello-api.tar.gz")
+	content = `{"name": "hello-api", "module": "github.com/acme/hello-api", "go_version": "1.22", "output_archive": "` + filepath.ToSlash(archive) + `"}`
+	if err := os.WriteFile(specPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write spec file: %v", err)
+	}
+	if code := run([]string{"new", "--spec", specPath}); code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+	if _, err := os.Stat(archive); err != nil {
+		t.Fatalf("expected the archive from output_archive: %v", err)
+	}
+}
+
+func TestRunInitSingleElementModule(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "go.mod"), []byte("module myapp\n\ngo 1.22\n"), 0o644); err != nil {
+		t.Fatalf("write go.mod: %v", err)
+	}
+
+	if code := run([]string{"init", "--dir", dir}); code != 0 {
+		t.Fatalf("expected init to adopt module myapp, got exit code %d", code)
+	}
+	if code := run([]string{"validate", "--dir", dir}); code != 0 {
+		t.Fatalf("expected the adopted scaffold to validate, got exit code %d", code)
+	}
+	if code := run([]string{"new", "--name", "myapp", "--module", "myapp", "--dir", filepath.Join(t.TempDir(), "myapp")}); code != 1 {
+		t.Fatalf("expected new to keep requiring a full module path, got exit code %d", code)
+	}
+}
+
+func TestFormatDryRunOutputListsPlannedFiles(t *testing.T) {
+	project := spec.ProjectSpec{
+		Name:     "hello-api",
+		Module:   "github.com/acme/hello-api",
+		Dir:      filepath.Join(t.TempDir(), "hello-api"),
+		HTTPPort: 8080,
+	}
+	plan, err := generator.Plan(project, ToolVersion)
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+
+	report, err := generator.DryRun(plan, generator.ConflictFail)
+	if err != nil {
+		t.Fatalf("dry run: %v", err)
+	}
+	if _, err := os.Stat(project.Dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("dry run created %s: %v", project.Dir, err)
+	}
+
+	out := formatDryRunOutput(plan, report, false)
+	for _, file := range plan.Files {
+		if !strings.Contains(out, file.OutputPath) || !strings.Contains(out, "sha256:"+file.SHA256()) {
+			t.Fatalf("expected output to list %s with its hash, got:\n%s", file.OutputPath, out)
+		}
+	}
+	if !strings.Contains(out, fmt.Sprintf("%d created, 0 skipped", len(plan.Files))) {
+		t.Fatalf("expected every file to be created, got:\n%s", out)
+	}
+	if strings.Contains(out, "--- go.mod") {
+		t.Fatalf("expected no file content without showContent, got:\n%s", out)
+	}
+
+	out = formatDryRunOutput(plan, report, true)
+	if !strings.Contains(out, "--- go.mod") || !strings.Contains(out, "module github.com/acme/hello-api") {
+		t.Fatalf("expected rendered go.mod content, got:\n%s", out)
+	}
+}
+
+func TestFormatDriftReportWarnsOnModeChange(t *testing.T) {
+	out := formatDriftReport([]spec.FileDrift{
+		{Path: "README.md", State: spec.FileUntouched, WantMode: 0o644, Mode: 0o644},
+		{Path: "scripts/dev.sh", State: spec.FileUntouched, WantMode: 0o755, Mode: 0o644},
+	})
+	if !strings.Contains(out, "warning: scripts/dev.sh has mode 0644, generated as 0755") {
+		t.Fatalf("expected mode warning, got:\n%s", out)
+	}
+	if strings.Contains(out, "warning: README.md") {
+		t.Fatalf("expected no warning for unchanged mode, got:\n%s", out)
+	}
+}
+
+func TestRunNewSpecFileFlagsOverride(t *testing.T) {
+	base := t.TempDir()
+	specPath := filepath.Join(base, "service.json")
+	content := `{"name": "from-file", "module": "github.com/acme/from-file", "http_port": 9090, "vars": {"shutdown_timeout_seconds": 20}}`
+	if err := os.WriteFile(specPath, []byte(content), 0o644); err != nil {
+		t.Fatalf("write spec file: %v", err)
+	}
+
+	dir := filepath.Join(base, "out")
+	code := run([]string{"new", "--spec", specPath, "--name", "from-flag", "--dir", dir})
+	if code != 0 {
+		t.Fatalf("expected exit code 0, got %d", code)
+	}
+
+	marker, err := spec.ReadMarker(filepath.Join(dir, spec.MarkerFileName))
+	if err != nil {
+		t.Fatalf("read marker: %v", err)
+	}
+	if marker.Spec.Name != "from-flag" || marker.Spec.Module != "github.com/acme/from-file" || marker.Spec.HTTPPort != 9090 {
+		t.Fatalf("unexpected marker spec: %+v", marker.Spec)
+	}
+	if marker.Spec.Vars["shutdown_timeout_seconds"] != "20" {
+		t.Fatalf("expected variable from spec file, got %v", marker.Spec.Vars)
+	}
+}
+
+func TestPromptProject(t *testing.T) {
+	answers := strings.Join([]string{
+		"Hello_API", // rejected name
+		"hello-api", // name
+		"",          // module: accept the suggestion from the prefix
+		"http",      // rejected port
+		"9090",      // port
+		"",          // pack: service-http
+		"pprof,metrics",
+	}, "\n") + "\n"
+
+	var out bytes.Buffer
+	prompter := ui.NewPrompter(strings.NewReader(answers), &out)
+	project, err := promptProject(prompter, spec.ProjectSpec{HTTPPort: 8080}, "github.com/acme/")
+	if err != nil {
+		t.Fatalf("prompt: %v\noutput:\n%s", err, out.String())
+	}
+
+	if project.Name != "hello-api" || project.Module != "github.com/acme/hello-api" || project.HTTPPort != 9090 || project.TemplateDir != "" {
+		t.Fatalf("unexpected project: %+v", project)
+	}
+	wantFeatures := map[string]bool{"docker": false, "metrics": true, "pprof": true}
+	if !reflect.DeepEqual(project.Features, wantFeatures) {
+		t.Fatalf("features = %v, want %v", project.Features, wantFeatures)
+	}
+	for _, want := range []string{"must match ^[a-z][a-z0-9-]*$", "[github.com/acme/hello-api]", "must be a number"} {
+		if !strings.Contains(out.String(), want) {
+			t.Fatalf("expected prompt output to contain %q, got:\n%s", want, out.String())
+		}
+	}
+}
@@ -0,0 +1,204 @@
+package generator
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/ridzuwary/gokit-scaffold/internal/outfs"
+	"github.com/ridzuwary/gokit-scaffold/internal/spec"
+)
+
+type ArchiveFormat string
+
+const (
+	ArchiveTarGz ArchiveFormat = "tar.gz"
+	ArchiveZip   ArchiveFormat = "zip"
+)
+
+// archiveModTime is the modification time of every archive entry, so the same
+// spec always produces the same bytes. It is the earliest time zip can store.
+var archiveModTime = time.Date(1980, time.January, 1, 0, 0, 0, 0, time.UTC)
+
+// ArchiveFormatFor picks the archive format from a file name's extension.
+func ArchiveFormatFor(name string) (ArchiveFormat, error) {
+	lower := strings.ToLower(name)
+	switch {
+	case strings.HasSuffix(lower, ".tar.gz"), strings.HasSuffix(lower, ".tgz"):
+		return ArchiveTarGz, nil
+	case strings.HasSuffix(lower, ".zip"):
+		return ArchiveZip, nil
+	}
+	return "", fmt.Errorf("unsupported archive %s (want a .tar.gz, .tgz or .zip file name)", name)
+}
+
+// GenerateArchive plans s as Generate does and writes the tree to the archive
+// file archivePath instead of a directory. An existing archive is only
+// replaced when s.Force is set.
+func GenerateArchive(s spec.ProjectSpec, opts Options, archivePath string) error {
+	format, err := ArchiveFormatFor(archivePath)
+	if err != nil {
+		return err
+	}
+	if info, err := os.Stat(archivePath); err == nil {
+		if info.IsDir() {
+			return fmt.Errorf("archive path %s is a directory", archivePath)
+		}
+		if !s.Force {
+			return fmt.Errorf("archive %s already exists (use --force to replace it)", archivePath)
+		}
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("stat archive %s: %w", archivePath, err)
+	}
+
+	plan, err := Plan(s, opts.Version)
+	if err != nil {
+		return err
+	}
+	if opts.Verify {
+		if err := Verify(plan); err != nil {
+			return err
+		}
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(archivePath), ".gokit-scaffold-tmp-*")
+	if err != nil {
+		return fmt.Errorf("create archive %s: %w", archivePath, err)
+	}
+	defer func() {
+		_ = os.Remove(tmp.Name())
+	}()
+
+	if err := WriteArchive(tmp, format, plan); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write archive %s: %w", archivePath, err)
+	}
+	if err := tmp.Chmod(spec.DefaultFileMode); err != nil {
+		_ = tmp.Close()
+		return fmt.Errorf("write archive %s: %w", archivePath, err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("write archive %s: %w", archivePath, err)
+	}
+	if err := os.Rename(tmp.Name(), archivePath); err != nil {
+		return fmt.Errorf("write archive %s: %w", archivePath, err)
+	}
+	return nil
+}
+
+// WriteArchive writes a plan's tree to w as an archive. Entries are relative
+// to the project root, sorted by path, owned by uid and gid 0 and dated
+// archiveModTime, so the output depends only on the plan.
+func WriteArchive(w io.Writer, format ArchiveFormat, plan RenderPlan) error {
+	out := outfs.NewMem()
+	if _, err := WriteTo(out, plan, ConflictFail); err != nil {
+		return err
+	}
+	entries := archiveEntries(out.Paths())
+
+	switch format {
+	case ArchiveTarGz:
+		return writeTarGz(w, out, entries)
+	case ArchiveZip:
+		return writeZip(w, out, entries)
+	}
+	return fmt.Errorf("unsupported archive format %q", format)
+}
+
+// archiveEntries returns files and every directory above them, sorted so each
+// directory comes before its contents. Directories end in "/".
+func archiveEntries(files []string) []string {
+	seen := map[string]bool{}
+	entries := make([]string, 0, len(files))
+	for _, file := range files {
+		entries = append(entries, file)
+		for dir := path.Dir(file); dir != "." && !seen[dir]; dir = path.Dir(dir) {
+			seen[dir] = true
+			entries = append(entries, dir+"/")
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool {
+		return strings.TrimSuffix(entries[i], "/") < strings.TrimSuffix(entries[j], "/")
+	})
+	return entries
+}
+
+func writeTarGz(w io.Writer, fsys fs.FS, entries []string) error {
+	gz, err := gzip.NewWriterLevel(w, gzip.BestCompression)
+	if err != nil {
+		return err
+	}
+	tw := tar.NewWriter(gz)
+
+	for _, name := range entries {
+		hdr := &tar.Header{Name: name, ModTime: archiveModTime, Format: tar.FormatPAX}
+		var content []byte
+		if strings.HasSuffix(name, "/") {
+			hdr.Typeflag = tar.TypeDir
+			hdr.Mode = 0o755
+		} else {
+			info, err := fs.Stat(fsys, name)
+			if err != nil {
+				return err
+			}
+			if content, err = fs.ReadFile(fsys, name); err != nil {
+				return err
+			}
+			hdr.Typeflag = tar.TypeReg
+			hdr.Mode = int64(info.Mode().Perm())
+			hdr.Size = int64(len(content))
+		}
+		if err := tw.WriteHeader(hdr); err != nil {
+			return fmt.Errorf("add %s: %w", name, err)
+		}
+		if _, err := tw.Write(content); err != nil {
+			return fmt.Errorf("add %s: %w", name, err)
+		}
+	}
+
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
+
+func writeZip(w io.Writer, fsys fs.FS, entries []string) error {
+	zw := zip.NewWriter(w)
+
+	for _, name := range entries {
+		hdr := &zip.FileHeader{Name: name, Modified: archiveModTime}
+		var content []byte
+		if strings.HasSuffix(name, "/") {
+			hdr.SetMode(fs.ModeDir | 0o755)
+		} else {
+			info, err := fs.Stat(fsys, name)
+			if err != nil {
+				return err
+			}
+			if content, err = fs.ReadFile(fsys, name); err != nil {
+				return err
+			}
+			hdr.Method = zip.Deflate
+			hdr.SetMode(info.Mode().Perm())
+		}
+		fw, err := zw.CreateHeader(hdr)
+		if err != nil {
+			return fmt.Errorf("add %s: %w", name, err)
+		}
+		if _, err := fw.Write(content); err != nil {
+			return fmt.Errorf("add %s: %w", name, err)
+		}
+	}
+
+	return zw.Close()
+}
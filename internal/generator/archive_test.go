@@ -0,0 +1,213 @@
+package generator
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/ridzuwary/gokit-scaffold/internal/spec"
+)
+
+func TestArchiveFormatFor(t *testing.T) {
+	tests := []struct {
+		name    string
+		want    ArchiveFormat
+		wantErr bool
+	}{
+		{name: "svc.tar.gz", want: ArchiveTarGz},
+		{name: "out/SVC.TGZ", want: ArchiveTarGz},
+		{name: "svc.zip", want: ArchiveZip},
+		{name: "svc.tar", wantErr: true},
+		{name: "svc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := ArchiveFormatFor(tt.name)
+		if tt.wantErr {
+			if err == nil {
+				t.Fatalf("%s: expected error, got %s", tt.name, got)
+			}
+			continue
+		}
+		if err != nil || got != tt.want {
+			t.Fatalf("%s: got %q, %v; want %q", tt.name, got, err, tt.want)
+		}
+	}
+}
+
+func TestWriteArchive(t *testing.T) {
+	plan, err := Plan(spec.ProjectSpec{
+		Name:      "hello-api",
+		Module:    "github.com/example/hello-api",
+		HTTPPort:  8080,
+		GoVersion: "1.22.0",
+	}, "0.1.0")
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+
+	for _, format := range []ArchiveFormat{ArchiveTarGz, ArchiveZip} {
+		t.Run(string(format), func(t *testing.T) {
+			planned := map[string]PlannedFile{}
+			for _, file := range plan.Files {
+				planned[file.OutputPath] = file
+			}
+
+			var first, second bytes.Buffer
+			if err := WriteArchive(&first, format, plan); err != nil {
+				t.Fatalf("write archive: %v", err)
+			}
+			if err := WriteArchive(&second, format, plan); err != nil {
+				t.Fatalf("write archive again: %v", err)
+			}
+			if !bytes.Equal(first.Bytes(), second.Bytes()) {
+				t.Fatalf("the same plan produced different archives")
+			}
+
+			entries := readArchive(t, format, first.Bytes())
+			var names []string
+			for _, entry := range entries {
+				names = append(names, entry.name)
+				if !entry.modTime.Equal(archiveModTime) {
+					t.Fatalf("%s: mtime %v, want %v", entry.name, entry.modTime, archiveModTime)
+				}
+				if entry.dir {
+					continue
+				}
+				file, ok := planned[entry.name]
+				if !ok {
+					t.Fatalf("archive has unplanned entry %s", entry.name)
+				}
+				if !bytes.Equal(entry.content, file.Content) {
+					t.Fatalf("%s: archived content differs from the plan", entry.name)
+				}
+				if entry.mode != file.mode() {
+					t.Fatalf("%s: mode %v, want %v", entry.name, entry.mode, file.mode())
+				}
+				delete(planned, entry.name)
+			}
+			for i := 1; i < len(names); i++ {
+				if names[i-1] >= names[i] {
+					t.Fatalf("entries out of order: %s before %s", names[i-1], names[i])
+				}
+			}
+			if names[0] != spec.MarkerFileName {
+				t.Fatalf("expected the marker first, got %s", names[0])
+			}
+			for name := range planned {
+				t.Fatalf("planned file %s is missing from the archive", name)
+			}
+		})
+	}
+}
+
+func TestGenerateArchiveRefusesExistingArchive(t *testing.T) {
+	archivePath := filepath.Join(t.TempDir(), "svc.zip")
+	if err := os.WriteFile(archivePath, []byte("keep"), 0o644); err != nil {
+		t.Fatalf("seed archive: %v", err)
+	}
+	project := spec.ProjectSpec{
+		Name:      "hello-api",
+		Module:    "github.com/example/hello-api",
+		HTTPPort:  8080,
+		GoVersion: "1.22.0",
+	}
+
+	if err := GenerateArchive(project, Options{Version: "0.1.0"}, archivePath); err == nil {
+		t.Fatalf("expected an existing archive to be refused")
+	}
+	if content, _ := os.ReadFile(archivePath); string(content) != "keep" {
+		t.Fatalf("existing archive was modified: %q", content)
+	}
+
+	project.Force = true
+	if err := GenerateArchive(project, Options{Version: "0.1.0"}, archivePath); err != nil {
+		t.Fatalf("generate with force: %v", err)
+	}
+	zr, err := zip.OpenReader(archivePath)
+	if err != nil {
+		t.Fatalf("open replaced archive: %v", err)
+	}
+	_ = zr.Close()
+	entries, err := os.ReadDir(filepath.Dir(archivePath))
+	if err != nil || len(entries) != 1 {
+		t.Fatalf("expected only the archive to remain, got %d entries: %v", len(entries), err)
+	}
+}
+
+type archiveEntry struct {
+	name    string
+	dir     bool
+	mode    os.FileMode
+	modTime time.Time
+	content []byte
+}
+
+func readArchive(t *testing.T, format ArchiveFormat, data []byte) []archiveEntry {
+	t.Helper()
+	var entries []archiveEntry
+
+	switch format {
+	case ArchiveTarGz:
+		gz, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("open gzip: %v", err)
+		}
+		tr := tar.NewReader(gz)
+		for {
+			hdr, err := tr.Next()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				t.Fatalf("read tar: %v", err)
+			}
+			if hdr.Uid != 0 || hdr.Gid != 0 || hdr.Uname != "" || hdr.Gname != "" {
+				t.Fatalf("%s: unexpected owner %d:%d (%s:%s)", hdr.Name, hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname)
+			}
+			content, err := io.ReadAll(tr)
+			if err != nil {
+				t.Fatalf("read %s: %v", hdr.Name, err)
+			}
+			entries = append(entries, archiveEntry{
+				name:    strings.TrimSuffix(hdr.Name, "/"),
+				dir:     hdr.Typeflag == tar.TypeDir,
+				mode:    hdr.FileInfo().Mode().Perm(),
+				modTime: hdr.ModTime,
+				content: content,
+			})
+		}
+	case ArchiveZip:
+		zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+		if err != nil {
+			t.Fatalf("open zip: %v", err)
+		}
+		for _, f := range zr.File {
+			rc, err := f.Open()
+			if err != nil {
+				t.Fatalf("open %s: %v", f.Name, err)
+			}
+			content, err := io.ReadAll(rc)
+			_ = rc.Close()
+			if err != nil {
+				t.Fatalf("read %s: %v", f.Name, err)
+			}
+			entries = append(entries, archiveEntry{
+				name:    strings.TrimSuffix(f.Name, "/"),
+				dir:     f.Mode().IsDir(),
+				mode:    f.Mode().Perm(),
+				modTime: f.Modified,
+				content: content,
+			})
+		}
+	}
+	return entries
+}
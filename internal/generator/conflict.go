@@ -0,0 +1,190 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+
+	"github.com/ridzuwary/gokit-scaffold/internal/outfs"
+)
+
+type ConflictPolicy string
+
+const (
+	ConflictFail      ConflictPolicy = "fail"
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictBackup    ConflictPolicy = "backup"
+)
+
+const backupSuffix = ".orig"
+
+func ConflictPolicies() []ConflictPolicy {
+	return []ConflictPolicy{ConflictSkip, ConflictOverwrite, ConflictBackup, ConflictFail}
+}
+
+func ParseConflictPolicy(value string) (ConflictPolicy, error) {
+	for _, policy := range ConflictPolicies() {
+		if string(policy) == value {
+			return policy, nil
+		}
+	}
+
+	names := make([]string, 0, len(ConflictPolicies()))
+	for _, policy := range ConflictPolicies() {
+		names = append(names, string(policy))
+	}
+	return "", fmt.Errorf("unknown conflict policy %q (want one of %s)", value, strings.Join(names, ", "))
+}
+
+type FileAction string
+
+const (
+	ActionCreated     FileAction = "created"
+	ActionSkipped     FileAction = "skipped"
+	ActionOverwritten FileAction = "overwritten"
+	ActionBackedUp    FileAction = "backed up"
+)
+
+type FileResult struct {
+	OutputPath string
+	Action     FileAction
+	BackupPath string
+}
+
+type Report struct {
+	Dir   string
+	Files []FileResult
+}
+
+func (r Report) Count(action FileAction) int {
+	n := 0
+	for _, file := range r.Files {
+		if file.Action == action {
+			n++
+		}
+	}
+	return n
+}
+
+// resolveConflicts decides what happens to every planned file before anything
+// is written, so a `fail` policy rejects the run without touching the target.
+func resolveConflicts(out outfs.FS, files []PlannedFile, policy ConflictPolicy) ([]FileResult, error) {
+	results := make([]FileResult, 0, len(files))
+	var conflicts []string
+
+	for _, file := range files {
+		rel := path.Clean(file.OutputPath)
+		result := FileResult{OutputPath: file.OutputPath, Action: ActionCreated}
+
+		info, err := out.Lstat(rel)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			results = append(results, result)
+			continue
+		case err != nil:
+			return nil, fmt.Errorf("stat existing file %s: %w", rel, err)
+		case info.IsDir():
+			return nil, fmt.Errorf("cannot write %s: a directory with that name already exists", file.OutputPath)
+		}
+
+		switch policy {
+		case ConflictSkip:
+			result.Action = ActionSkipped
+		case ConflictOverwrite:
+			result.Action = ActionOverwritten
+		case ConflictBackup:
+			result.Action = ActionBackedUp
+			result.BackupPath = file.OutputPath + backupSuffix
+			if _, err := out.Lstat(rel + backupSuffix); err == nil {
+				return nil, fmt.Errorf("cannot back up %s: %s already exists", file.OutputPath, result.BackupPath)
+			} else if !errors.Is(err, fs.ErrNotExist) {
+				return nil, fmt.Errorf("stat backup file %s: %w", rel+backupSuffix, err)
+			}
+		default:
+			conflicts = append(conflicts, file.OutputPath)
+		}
+		results = append(results, result)
+	}
+
+	if len(conflicts) > 0 {
+		return nil, fmt.Errorf("refusing to replace existing files (use --on-conflict=skip|overwrite|backup): %s", strings.Join(conflicts, ", "))
+	}
+
+	return results, nil
+}
+
+type undoStep func() error
+
+// commitFiles moves staged files into place one at a time. Every step is
+// journaled so a failure part-way through restores the original tree.
+func commitFiles(out outfs.FS, stage string, results []FileResult) (err error) {
+	var journal []undoStep
+	defer func() {
+		if err == nil {
+			return
+		}
+		for i := len(journal) - 1; i >= 0; i-- {
+			_ = journal[i]()
+		}
+	}()
+
+	displaced := path.Join(stage, ".displaced")
+	for _, result := range results {
+		if result.Action == ActionSkipped {
+			continue
+		}
+
+		rel := path.Clean(result.OutputPath)
+		src := path.Join(stage, "tree", rel)
+		dst := rel
+
+		created, err := mkdirAllIn(out, path.Dir(dst))
+		if err != nil {
+			return fmt.Errorf("create output directory for %s: %w", dst, err)
+		}
+		if created != "" {
+			journal = append(journal, func() error { return out.RemoveAll(created) })
+		}
+
+		switch result.Action {
+		case ActionBackedUp:
+			backup := path.Clean(result.BackupPath)
+			if err := copyFile(out, dst, backup); err != nil {
+				return fmt.Errorf("back up %s: %w", dst, err)
+			}
+			journal = append(journal, func() error { return out.Remove(backup) })
+			fallthrough
+		case ActionOverwritten:
+			aside := path.Join(displaced, rel)
+			if err := out.MkdirAll(path.Dir(aside), 0o755); err != nil {
+				return fmt.Errorf("prepare replacement of %s: %w", dst, err)
+			}
+			if err := out.Rename(dst, aside); err != nil {
+				return fmt.Errorf("replace %s: %w", dst, err)
+			}
+			journal = append(journal, func() error { return out.Rename(aside, dst) })
+		}
+
+		if err := out.Rename(src, dst); err != nil {
+			return fmt.Errorf("move staged file into %s: %w", dst, err)
+		}
+		journal = append(journal, func() error { return out.Rename(dst, src) })
+	}
+
+	return nil
+}
+
+func copyFile(out outfs.FS, src, dst string) error {
+	info, err := fs.Stat(out, src)
+	if err != nil {
+		return err
+	}
+	content, err := fs.ReadFile(out, src)
+	if err != nil {
+		return err
+	}
+	return out.WriteFile(dst, content, info.Mode().Perm())
+}
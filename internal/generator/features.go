@@ -0,0 +1,121 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/ridzuwary/gokit-scaffold/templates"
+)
+
+const featureConditionPrefix = "features."
+
+// resolveFeatures applies the user's --with/--without choices on top of the
+// pack defaults and returns the state of every declared feature.
+func resolveFeatures(declared []templates.PackFeature, choices map[string]bool) (map[string]bool, error) {
+	resolved := make(map[string]bool, len(declared))
+	for _, feature := range declared {
+		resolved[feature.Name] = feature.Default
+	}
+
+	var unknown []string
+	for name, enabled := range choices {
+		if _, ok := resolved[name]; !ok {
+			unknown = append(unknown, name)
+			continue
+		}
+		resolved[name] = enabled
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, fmt.Errorf("unknown features: %s (available: %s)", strings.Join(unknown, ", "), strings.Join(featureNames(declared), ", "))
+	}
+
+	return resolved, nil
+}
+
+// ExactFeatures selects exactly the named features of pack, the way a marker's
+// `spec.features` records them: every other declared feature is turned off,
+// whatever its default.
+func ExactFeatures(pack Pack, enabled []string) map[string]bool {
+	features := make(map[string]bool, len(pack.Features)+len(enabled))
+	for _, feature := range pack.Features {
+		features[feature.Name] = false
+	}
+	for _, name := range enabled {
+		features[name] = true
+	}
+	return features
+}
+
+// FeatureOutputs maps each feature of the pack to the output paths that are
+// only generated when it is enabled.
+func (p Pack) FeatureOutputs() map[string][]string {
+	outputs := make(map[string][]string, len(p.Features))
+	for _, entry := range p.Entries {
+		if entry.When == "" {
+			continue
+		}
+		feature, negate, err := parseCondition(entry.When)
+		if err != nil || negate {
+			continue
+		}
+		outputs[feature] = append(outputs[feature], entry.OutputPath)
+	}
+	return outputs
+}
+
+func enabledFeatures(features map[string]bool) []string {
+	var names []string
+	for name, enabled := range features {
+		if enabled {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func featureNames(declared []templates.PackFeature) []string {
+	names := make([]string, 0, len(declared))
+	for _, feature := range declared {
+		names = append(names, feature.Name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// parseCondition parses a manifest `when` clause: `features.<name>` or its
+// negation `!features.<name>`.
+func parseCondition(when string) (feature string, negate bool, err error) {
+	cond := strings.TrimSpace(when)
+	if strings.HasPrefix(cond, "!") {
+		negate = true
+		cond = strings.TrimSpace(strings.TrimPrefix(cond, "!"))
+	}
+	if !strings.HasPrefix(cond, featureConditionPrefix) || cond == featureConditionPrefix {
+		return "", false, fmt.Errorf("invalid condition %q (want features.<name> or !features.<name>)", when)
+	}
+	return strings.TrimPrefix(cond, featureConditionPrefix), negate, nil
+}
+
+func entryEnabled(entry ManifestEntry, features map[string]bool) bool {
+	if entry.When == "" {
+		return true
+	}
+	feature, negate, err := parseCondition(entry.When)
+	if err != nil {
+		return false
+	}
+	return features[feature] != negate
+}
+
+func activeEntries(entries []ManifestEntry, features map[string]bool) []ManifestEntry {
+	active := make([]ManifestEntry, 0, len(entries))
+	for _, entry := range entries {
+		if entryEnabled(entry, features) {
+			active = append(active, entry)
+		}
+	}
+	return active
+}
@@ -0,0 +1,202 @@
+package generator
+
+import (
+	"fmt"
+	"go/token"
+	"go/version"
+	"path"
+	"strconv"
+	"strings"
+	"text/template"
+	"unicode"
+)
+
+// FuncMap returns the helpers available to every template in a pack.
+//
+// Case conversion splits words on any non-alphanumeric character and on case
+// changes, so "hello-api", "hello_api" and "HelloAPI" all read as the words
+// "hello" and "api". For "hello-api":
+//
+//	snake            hello_api
+//	kebab            hello-api
+//	camel            helloApi
+//	pascal           HelloApi
+//	screamingSnake   HELLO_API
+//	lower, upper     strings.ToLower, strings.ToUpper
+//
+// Go identifiers (never empty, never a keyword, never starting with a digit):
+//
+//	goIdent     helloApi
+//	goPackage   helloapi
+//
+// Strings and paths (piped value goes last, as in {{ .Module | base }}):
+//
+//	quote s               Go double-quoted string literal
+//	squote s              single-quoted string (shell, YAML)
+//	indent n s            prefix every non-empty line with n spaces
+//	nindent n s           newline followed by indent n s
+//	trimPrefix prefix s   strings.TrimPrefix
+//	trimSuffix suffix s   strings.TrimSuffix
+//	base p                last element of a slash-separated path
+//	join sep list         join a list of strings (or values) with sep
+//
+// Versions (as in {{ if .GoVersion | goAtLeast "1.22" }}):
+//
+//	goAtLeast min v       v is Go release min or later; both without "go"
+func FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"snake":          snakeCase,
+		"kebab":          kebabCase,
+		"camel":          camelCase,
+		"pascal":         pascalCase,
+		"screamingSnake": screamingSnakeCase,
+		"lower":          strings.ToLower,
+		"upper":          strings.ToUpper,
+		"goIdent":        goIdent,
+		"goPackage":      goPackage,
+		"quote":          strconv.Quote,
+		"squote":         squote,
+		"indent":         indent,
+		"nindent":        nindent,
+		"trimPrefix":     func(prefix, s string) string { return strings.TrimPrefix(s, prefix) },
+		"trimSuffix":     func(suffix, s string) string { return strings.TrimSuffix(s, suffix) },
+		"base":           path.Base,
+		"join":           join,
+		"goAtLeast":      goAtLeast,
+	}
+}
+
+func splitWords(s string) []string {
+	var words []string
+	var current []rune
+
+	flush := func() {
+		if len(current) > 0 {
+			words = append(words, string(current))
+			current = current[:0]
+		}
+	}
+
+	runes := []rune(s)
+	for i, r := range runes {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			flush()
+			continue
+		}
+		if unicode.IsUpper(r) && len(current) > 0 {
+			prev := runes[i-1]
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if unicode.IsLower(prev) || unicode.IsDigit(prev) || (unicode.IsUpper(prev) && nextLower) {
+				flush()
+			}
+		}
+		current = append(current, r)
+	}
+	flush()
+
+	return words
+}
+
+func snakeCase(s string) string {
+	return strings.ToLower(strings.Join(splitWords(s), "_"))
+}
+
+func kebabCase(s string) string {
+	return strings.ToLower(strings.Join(splitWords(s), "-"))
+}
+
+func screamingSnakeCase(s string) string {
+	return strings.ToUpper(strings.Join(splitWords(s), "_"))
+}
+
+func pascalCase(s string) string {
+	var b strings.Builder
+	for _, word := range splitWords(s) {
+		b.WriteString(capitalize(word))
+	}
+	return b.String()
+}
+
+func camelCase(s string) string {
+	words := splitWords(s)
+	if len(words) == 0 {
+		return ""
+	}
+
+	var b strings.Builder
+	b.WriteString(strings.ToLower(words[0]))
+	for _, word := range words[1:] {
+		b.WriteString(capitalize(word))
+	}
+	return b.String()
+}
+
+func capitalize(word string) string {
+	runes := []rune(strings.ToLower(word))
+	runes[0] = unicode.ToUpper(runes[0])
+	return string(runes)
+}
+
+func goIdent(s string) string {
+	return sanitizeIdent(camelCase(s))
+}
+
+func goPackage(s string) string {
+	return sanitizeIdent(strings.ToLower(strings.Join(splitWords(s), "")))
+}
+
+func sanitizeIdent(ident string) string {
+	if ident == "" {
+		return "_"
+	}
+	if r := []rune(ident)[0]; unicode.IsDigit(r) {
+		ident = "_" + ident
+	}
+	if token.IsKeyword(ident) {
+		ident += "_"
+	}
+	return ident
+}
+
+func squote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
+
+func indent(n int, s string) string {
+	pad := strings.Repeat(" ", n)
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		if line != "" {
+			lines[i] = pad + line
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+func nindent(n int, s string) string {
+	return "\n" + indent(n, s)
+}
+
+func goAtLeast(min, v string) (bool, error) {
+	for _, s := range []string{min, v} {
+		if !version.IsValid("go" + s) {
+			return false, fmt.Errorf("goAtLeast: %q is not a Go version", s)
+		}
+	}
+	return version.Compare("go"+v, "go"+min) >= 0, nil
+}
+
+func join(sep string, list any) (string, error) {
+	switch items := list.(type) {
+	case []string:
+		return strings.Join(items, sep), nil
+	case []any:
+		parts := make([]string, 0, len(items))
+		for _, item := range items {
+			parts = append(parts, fmt.Sprint(item))
+		}
+		return strings.Join(parts, sep), nil
+	default:
+		return "", fmt.Errorf("join: unsupported list type %T", list)
+	}
+}
@@ -0,0 +1,98 @@
+package generator
+
+import (
+	"strings"
+	"testing"
+	"text/template"
+)
+
+func TestFuncMapCaseConversion(t *testing.T) {
+	cases := []struct {
+		in             string
+		snake          string
+		kebab          string
+		camel          string
+		pascal         string
+		screamingSnake string
+		goIdent        string
+		goPackage      string
+	}{
+		{"hello-api", "hello_api", "hello-api", "helloApi", "HelloApi", "HELLO_API", "helloApi", "helloapi"},
+		{"HelloAPI", "hello_api", "hello-api", "helloApi", "HelloApi", "HELLO_API", "helloApi", "helloapi"},
+		{"HTTPServer", "http_server", "http-server", "httpServer", "HttpServer", "HTTP_SERVER", "httpServer", "httpserver"},
+		{"orders_v2-api", "orders_v2_api", "orders-v2-api", "ordersV2Api", "OrdersV2Api", "ORDERS_V2_API", "ordersV2Api", "ordersv2api"},
+		{"3d-render", "3d_render", "3d-render", "3dRender", "3dRender", "3D_RENDER", "_3dRender", "_3drender"},
+		{"type", "type", "type", "type", "Type", "TYPE", "type_", "type_"},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.in, func(t *testing.T) {
+			got := map[string]string{
+				"snake":          snakeCase(tc.in),
+				"kebab":          kebabCase(tc.in),
+				"camel":          camelCase(tc.in),
+				"pascal":         pascalCase(tc.in),
+				"screamingSnake": screamingSnakeCase(tc.in),
+				"goIdent":        goIdent(tc.in),
+				"goPackage":      goPackage(tc.in),
+			}
+			want := map[string]string{
+				"snake":          tc.snake,
+				"kebab":          tc.kebab,
+				"camel":          tc.camel,
+				"pascal":         tc.pascal,
+				"screamingSnake": tc.screamingSnake,
+				"goIdent":        tc.goIdent,
+				"goPackage":      tc.goPackage,
+			}
+			for fn, w := range want {
+				if got[fn] != w {
+					t.Fatalf("%s(%q) = %q, want %q", fn, tc.in, got[fn], w)
+				}
+			}
+		})
+	}
+}
+
+func TestFuncMapInTemplates(t *testing.T) {
+	data := map[string]any{
+		"Name":      "hello-api",
+		"Module":    "github.com/example/hello-api",
+		"Features":  []string{"docker", "metrics"},
+		"Body":      "a: 1\nb: 2",
+		"GoVersion": "1.21.5",
+	}
+
+	cases := []struct {
+		tpl  string
+		want string
+	}{
+		{`{{ .Name | screamingSnake }}_`, "HELLO_API_"},
+		{`package {{ .Name | goPackage }}`, "package helloapi"},
+		{`{{ .Module | trimPrefix "github.com/" }}`, "example/hello-api"},
+		{`{{ .Module | base }}`, "hello-api"},
+		{`{{ .Features | join ", " }}`, "docker, metrics"},
+		{`{{ .Name | quote }} {{ .Name | squote }}`, `"hello-api" 'hello-api'`},
+		{`x:{{ .Body | nindent 2 }}`, "x:\n  a: 1\n  b: 2"},
+		{`{{ .GoVersion | goAtLeast "1.21" }} {{ .GoVersion | goAtLeast "1.22" }}`, "true false"},
+	}
+
+	for _, tc := range cases {
+		tpl, err := template.New("t").Funcs(FuncMap()).Parse(tc.tpl)
+		if err != nil {
+			t.Fatalf("parse %q: %v", tc.tpl, err)
+		}
+		var out strings.Builder
+		if err := tpl.Execute(&out, data); err != nil {
+			t.Fatalf("execute %q: %v", tc.tpl, err)
+		}
+		if out.String() != tc.want {
+			t.Fatalf("%s = %q, want %q", tc.tpl, out.String(), tc.want)
+		}
+	}
+
+	tpl := template.Must(template.New("t").Funcs(FuncMap()).Parse(`{{ "go1.22" | goAtLeast "1.22" }}`))
+	if err := tpl.Execute(&strings.Builder{}, nil); err == nil {
+		t.Fatal("expected goAtLeast to reject a version with the go prefix")
+	}
+}
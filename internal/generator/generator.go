@@ -2,99 +2,511 @@ package generator
 
 import (
 	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"go/format"
+	"go/scanner"
+	"io/fs"
 	"os"
+	"path"
 	"path/filepath"
+	"runtime"
+	"slices"
+	"sort"
+	"strings"
 	"text/template"
 
+	"github.com/ridzuwary/gokit-scaffold/internal/outfs"
 	"github.com/ridzuwary/gokit-scaffold/internal/spec"
-	"github.com/ridzuwary/gokit-scaffold/templates"
 )
 
 // cspell:words ridzuwary gokit tmpl
 
 type templateData struct {
-	Name     string
-	Module   string
-	HTTPPort int
-	Version  string
+	Name      string
+	Module    string
+	HTTPPort  int
+	GoVersion string
+	Version   string
+	Vars      map[string]any
+	Features  map[string]bool
 }
 
-func Generate(s spec.ProjectSpec, version string) error {
-	data := templateData{
-		Name:     s.Name,
-		Module:   s.Module,
-		HTTPPort: s.HTTPPort,
-		Version:  version,
+func newTemplateData(s spec.ProjectSpec, version string) templateData {
+	return templateData{
+		Name:      s.Name,
+		Module:    s.Module,
+		HTTPPort:  s.HTTPPort,
+		GoVersion: s.GoVersion,
+		Version:   version,
 	}
+}
+
+type RenderPlan struct {
+	Dir          string
+	TemplatePack string
+	Files        []PlannedFile
+}
+
+type PlannedFile struct {
+	TemplatePath string
+	OutputPath   string
+	Content      []byte
+	// Mode defaults to spec.DefaultFileMode when zero.
+	Mode fs.FileMode
+}
+
+func (f PlannedFile) Size() int {
+	return len(f.Content)
+}
+
+func (f PlannedFile) SHA256() string {
+	sum := sha256.Sum256(f.Content)
+	return hex.EncodeToString(sum[:])
+}
 
-	entries, err := Manifest(ServiceHTTPTemplatePack)
+func (f PlannedFile) mode() fs.FileMode {
+	if f.Mode == 0 {
+		return spec.DefaultFileMode
+	}
+	return f.Mode
+}
+
+func Plan(s spec.ProjectSpec, version string) (RenderPlan, error) {
+	pack, err := ResolvePack(s)
 	if err != nil {
-		return err
+		return RenderPlan{}, err
+	}
+	return planPack(pack, s, version)
+}
+
+// RenderFile renders the single output of pack at outputPath for s, through
+// the same pipeline as Plan. The marker can be rendered too.
+func RenderFile(pack Pack, s spec.ProjectSpec, outputPath, version string) (PlannedFile, error) {
+	plan, err := planPack(pack, s, version)
+	if err != nil {
+		return PlannedFile{}, err
+	}
+	outputPath = path.Clean(filepath.ToSlash(outputPath))
+	for _, file := range plan.Files {
+		if file.OutputPath == outputPath {
+			return file, nil
+		}
 	}
 
+	for feature, outputs := range pack.FeatureOutputs() {
+		if slices.Contains(outputs, outputPath) {
+			return PlannedFile{}, fmt.Errorf("template pack %s only generates %s with --with %s", pack.Name, outputPath, feature)
+		}
+	}
+	return PlannedFile{}, fmt.Errorf("template pack %s does not generate %s (see `gokit-scaffold packs show %s`)", pack.Name, outputPath, pack.Name)
+}
+
+func planPack(pack Pack, s spec.ProjectSpec, version string) (RenderPlan, error) {
+	if s.GoVersion == "" {
+		s.GoVersion = spec.DefaultGoVersion()
+		if s.GoVersion == "" {
+			return RenderPlan{}, fmt.Errorf("cannot detect the Go version of toolchain %s; pass --go-version", runtime.Version())
+		}
+	}
+
+	vars, typed, err := resolveVariables(pack.Variables, s.Vars)
+	if err != nil {
+		return RenderPlan{}, fmt.Errorf("template pack %s: %w", pack.Name, err)
+	}
+	features, err := resolveFeatures(pack.Features, s.Features)
+	if err != nil {
+		return RenderPlan{}, fmt.Errorf("template pack %s: %w", pack.Name, err)
+	}
+	data := newTemplateData(s, version)
+	data.Vars = typed
+	data.Features = features
+
+	plan, err := planFrom(pack.FS, activeEntries(pack.Entries, features), data, s.Dir)
+	if err != nil {
+		return RenderPlan{}, err
+	}
+	plan.TemplatePack = pack.Name
+
+	resolved := s
+	resolved.Vars = vars
+	resolved.Features = features
+	marker, err := renderMarker(pack, resolved, version, plan.Files)
+	if err != nil {
+		return RenderPlan{}, err
+	}
+	plan.Files = append(plan.Files, marker)
+	sort.Slice(plan.Files, func(i, j int) bool {
+		return plan.Files[i].OutputPath < plan.Files[j].OutputPath
+	})
+
+	return plan, nil
+}
+
+func planFrom(fsys fs.FS, entries []ManifestEntry, data templateData, dir string) (RenderPlan, error) {
+	plan := RenderPlan{
+		Dir:   dir,
+		Files: make([]PlannedFile, 0, len(entries)),
+	}
+	seen := make(map[string]string, len(entries))
 	for _, entry := range entries {
-		if err := renderToFile(s.Dir, entry, data); err != nil {
-			return err
+		outputPath, err := renderOutputPath(entry, data)
+		if err != nil {
+			return RenderPlan{}, err
+		}
+		if other, ok := seen[outputPath]; ok {
+			return RenderPlan{}, fmt.Errorf("templates %s and %s both render to %s", other, entry.TemplatePath, outputPath)
+		}
+		seen[outputPath] = entry.TemplatePath
+		entry.OutputPath = outputPath
+
+		content, err := render(fsys, entry, data)
+		if err != nil {
+			return RenderPlan{}, err
+		}
+		if !entry.Copy && strings.HasSuffix(entry.OutputPath, ".go") {
+			content, err = formatGo(entry, content)
+			if err != nil {
+				return RenderPlan{}, err
+			}
 		}
+		plan.Files = append(plan.Files, PlannedFile{
+			TemplatePath: entry.TemplatePath,
+			OutputPath:   entry.OutputPath,
+			Content:      content,
+			Mode:         entry.Mode,
+		})
 	}
 
-	return nil
+	return plan, nil
+}
+
+func ResolvePack(s spec.ProjectSpec) (Pack, error) {
+	if s.TemplateDir != "" {
+		return LoadPackDir(s.TemplateDir)
+	}
+	return EmbeddedPack(spec.TemplatePackName)
 }
 
-func renderToFile(baseDir string, entry ManifestEntry, data templateData) error {
-	body, err := templates.FS.ReadFile(entry.TemplatePath)
+// renderMarker builds the .gokit-scaffold marker from the rendered files so it
+// can record the SHA-256 of every output for drift detection.
+func renderMarker(pack Pack, s spec.ProjectSpec, version string, files []PlannedFile) (PlannedFile, error) {
+	marker := spec.Marker{
+		Tool:         spec.MarkerTool,
+		Version:      version,
+		TemplatePack: pack.Name,
+		Spec: spec.MarkerSpec{
+			Name:      s.Name,
+			Module:    s.Module,
+			HTTPPort:  s.HTTPPort,
+			GoVersion: s.GoVersion,
+			Vars:      s.Vars,
+			Features:  enabledFeatures(s.Features),
+		},
+		Files: make(map[string]string, len(files)),
+	}
+	if len(pack.Layers) > 1 {
+		marker.TemplateLayers = pack.Layers
+	}
+	if pack.Origin != EmbeddedOrigin {
+		marker.TemplateDir = pack.Origin
+		marker.Required = pack.Required
+	}
+	for _, file := range files {
+		marker.Files[file.OutputPath] = file.SHA256()
+		if mode := file.mode(); mode != spec.DefaultFileMode {
+			if marker.Modes == nil {
+				marker.Modes = map[string]string{}
+			}
+			marker.Modes[file.OutputPath] = spec.FormatFileMode(mode)
+		}
+	}
+
+	content, err := json.MarshalIndent(marker, "", "  ")
 	if err != nil {
-		return fmt.Errorf("read template %s: %w", entry.TemplatePath, err)
+		return PlannedFile{}, fmt.Errorf("encode %s marker: %w", spec.MarkerFileName, err)
 	}
 
-	tpl, err := template.New(entry.TemplatePath).Parse(string(body))
+	return PlannedFile{
+		OutputPath: spec.MarkerFileName,
+		Content:    append(content, '\n'),
+	}, nil
+}
+
+type Options struct {
+	Version    string
+	OnConflict ConflictPolicy
+	// Verify type-checks the rendered Go packages before anything is written.
+	Verify bool
+}
+
+func Generate(s spec.ProjectSpec, opts Options) (Report, error) {
+	plan, err := Plan(s, opts.Version)
 	if err != nil {
-		return fmt.Errorf("parse template %s: %w", entry.TemplatePath, err)
+		return Report{}, err
+	}
+	if opts.Verify {
+		if err := Verify(plan); err != nil {
+			return Report{}, err
+		}
 	}
 
-	var out bytes.Buffer
-	if err := tpl.Execute(&out, data); err != nil {
-		return fmt.Errorf("render template %s: %w", entry.TemplatePath, err)
+	return writePlan(plan, opts.OnConflict)
+}
+
+// Write writes a plan from Plan to its directory, as Generate does. The spec
+// the plan came from must have been validated.
+func Write(plan RenderPlan, policy ConflictPolicy) (Report, error) {
+	return writePlan(plan, policy)
+}
+
+// WriteTo writes a plan's files into out instead of its directory, with the
+// same conflict handling and rollback as Write. plan.Dir is only reported.
+func WriteTo(out outfs.FS, plan RenderPlan, policy ConflictPolicy) (Report, error) {
+	results, err := resolveConflicts(out, plan.Files, policy)
+	if err != nil {
+		return Report{}, err
 	}
+	if err := stageAndCommit(out, plan.Files, results); err != nil {
+		return Report{}, err
+	}
+	return Report{Dir: plan.Dir, Files: results}, nil
+}
 
-	target := filepath.Join(baseDir, filepath.Clean(entry.OutputPath))
-	if err := os.MkdirAll(filepath.Dir(target), 0o755); err != nil {
-		return fmt.Errorf("create output directory for %s: %w", target, err)
+// DryRun writes a plan into memory instead of its directory and reports what
+// Write would do: the memory starts out with whatever the plan's files, their
+// directories and backups would collide with on disk, so conflicts, skips and
+// backups come out as they would for real.
+func DryRun(plan RenderPlan, policy ConflictPolicy) (Report, error) {
+	target, err := filepath.Abs(filepath.Clean(plan.Dir))
+	if err != nil {
+		return Report{}, fmt.Errorf("resolve directory: %w", err)
+	}
+	if info, err := os.Stat(target); err == nil && !info.IsDir() {
+		return Report{}, fmt.Errorf("target path exists and is not a directory: %s", target)
 	}
 
-	if err := writeFileAtomic(target, out.Bytes(), 0o644); err != nil {
-		return fmt.Errorf("write output file %s: %w", target, err)
+	mem := outfs.NewMem()
+	for _, file := range plan.Files {
+		rel := path.Clean(file.OutputPath)
+		for _, name := range []string{rel, rel + backupSuffix} {
+			if err := copyExisting(mem, target, name); err != nil {
+				return Report{}, err
+			}
+		}
 	}
+	return WriteTo(mem, plan, policy)
+}
 
+// copyExisting copies name and the directories above it from dir on disk into
+// mem, as far as they exist. File contents are copied so that backups work.
+func copyExisting(mem *outfs.Mem, dir, name string) error {
+	parts := strings.Split(name, "/")
+	for i := range parts {
+		rel := strings.Join(parts[:i+1], "/")
+		full := filepath.Join(dir, filepath.FromSlash(rel))
+		info, err := os.Stat(full)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			return nil
+		case err != nil:
+			return fmt.Errorf("stat existing file %s: %w", rel, err)
+		case !info.IsDir():
+			content, err := os.ReadFile(full)
+			if err != nil {
+				return fmt.Errorf("read existing file %s: %w", rel, err)
+			}
+			return mem.WriteFile(rel, content, info.Mode().Perm())
+		}
+		if err := mem.MkdirAll(rel, info.Mode().Perm()); err != nil {
+			return err
+		}
+	}
 	return nil
 }
 
-func writeFileAtomic(path string, content []byte, mode os.FileMode) error {
-	dir := filepath.Dir(path)
-	tmp, err := os.CreateTemp(dir, ".gokit-scaffold-tmp-*")
+// writePlan writes a plan to its directory on disk, creating it if needed. A
+// failed generation never leaves a half-populated target behind: the files
+// are staged inside the target and committed with an undo journal, and any
+// directory created for the target is removed again.
+func writePlan(plan RenderPlan, policy ConflictPolicy) (report Report, err error) {
+	target, err := filepath.Abs(filepath.Clean(plan.Dir))
 	if err != nil {
-		return err
+		return Report{}, fmt.Errorf("resolve directory: %w", err)
 	}
-	tmpPath := tmp.Name()
 
+	if info, err := os.Stat(target); err == nil && !info.IsDir() {
+		return Report{}, fmt.Errorf("target path exists and is not a directory: %s", target)
+	}
+	created, err := mkdirAllTracked(target)
+	if err != nil {
+		return Report{}, fmt.Errorf("create target directory %s: %w", target, err)
+	}
 	defer func() {
-		_ = os.Remove(tmpPath)
+		if err != nil && created != "" {
+			_ = os.RemoveAll(created)
+		}
 	}()
 
-	if _, err := tmp.Write(content); err != nil {
-		_ = tmp.Close()
-		return err
+	return WriteTo(outfs.Dir(target), plan, policy)
+}
+
+// stageAndCommit writes every file that is not skipped into a hidden staging
+// directory of out, then moves them into place. The staging directory is
+// removed either way.
+func stageAndCommit(out outfs.FS, files []PlannedFile, results []FileResult) error {
+	stage := ".gokit-scaffold-stage-" + strings.ToLower(rand.Text()[:10])
+	if err := out.MkdirAll(path.Join(stage, "tree"), 0o755); err != nil {
+		return fmt.Errorf("create staging directory: %w", err)
 	}
-	if err := tmp.Chmod(mode); err != nil {
-		_ = tmp.Close()
-		return err
+	defer func() {
+		_ = out.RemoveAll(stage)
+	}()
+
+	for i, file := range files {
+		if results[i].Action == ActionSkipped {
+			continue
+		}
+		if err := writeToDir(out, path.Join(stage, "tree"), file); err != nil {
+			return err
+		}
 	}
-	if err := tmp.Close(); err != nil {
-		return err
+	return commitFiles(out, stage, results)
+}
+
+// mkdirAllTracked behaves like os.MkdirAll and returns the top-most directory
+// it had to create, or "" when the path already existed.
+func mkdirAllTracked(dir string) (string, error) {
+	var missing string
+	for current := dir; ; current = filepath.Dir(current) {
+		if _, err := os.Stat(current); err == nil {
+			break
+		} else if !errors.Is(err, os.ErrNotExist) {
+			return "", err
+		}
+		missing = current
+		if filepath.Dir(current) == current {
+			break
+		}
+	}
+	if missing == "" {
+		return "", nil
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		_ = os.RemoveAll(missing)
+		return "", err
 	}
-	if err := os.Rename(tmpPath, path); err != nil {
-		return err
+	return missing, nil
+}
+
+// mkdirAllIn is mkdirAllTracked for a directory of out.
+func mkdirAllIn(out outfs.FS, dir string) (string, error) {
+	var missing string
+	for current := dir; current != "."; current = path.Dir(current) {
+		if _, err := fs.Stat(out, current); err == nil {
+			break
+		} else if !errors.Is(err, fs.ErrNotExist) {
+			return "", err
+		}
+		missing = current
+	}
+	if missing == "" {
+		return "", nil
+	}
+
+	if err := out.MkdirAll(dir, 0o755); err != nil {
+		_ = out.RemoveAll(missing)
+		return "", err
+	}
+	return missing, nil
+}
+
+func render(fsys fs.FS, entry ManifestEntry, data templateData) ([]byte, error) {
+	body, err := fs.ReadFile(entry.source(fsys), entry.TemplatePath)
+	if err != nil {
+		return nil, fmt.Errorf("read template %s: %w", entry.TemplatePath, err)
+	}
+
+	if entry.Copy {
+		return body, nil
+	}
+
+	left, right := entry.delims()
+	tpl, err := template.New(entry.TemplatePath).Funcs(FuncMap()).Delims(left, right).Parse(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("parse template %s: %w", entry.TemplatePath, err)
+	}
+
+	var out bytes.Buffer
+	if err := tpl.Execute(&out, data); err != nil {
+		return nil, fmt.Errorf("render template %s: %w", entry.TemplatePath, err)
+	}
+
+	return out.Bytes(), nil
+}
+
+// renderOutputPath renders an entry's output path as a template and checks the
+// result still names a file inside the target directory.
+func renderOutputPath(entry ManifestEntry, data templateData) (string, error) {
+	left, right := entry.delims()
+	if !strings.Contains(entry.OutputPath, left) {
+		return entry.OutputPath, nil
+	}
+
+	tpl, err := template.New(entry.OutputPath).Funcs(FuncMap()).Delims(left, right).Parse(entry.OutputPath)
+	if err != nil {
+		return "", fmt.Errorf("parse output path of template %s: %w", entry.TemplatePath, err)
+	}
+	var out strings.Builder
+	if err := tpl.Execute(&out, data); err != nil {
+		return "", fmt.Errorf("render output path of template %s: %w", entry.TemplatePath, err)
+	}
+
+	rendered := path.Clean(out.String())
+	// Backslashes are separators on Windows and could smuggle in "..".
+	if !fs.ValidPath(rendered) || rendered == "." || strings.Contains(rendered, `\`) {
+		return "", fmt.Errorf("output path %q of template %s renders to %q, which is outside the target directory", entry.OutputPath, entry.TemplatePath, out.String())
+	}
+	if rendered == spec.MarkerFileName {
+		return "", fmt.Errorf("output path %q of template %s renders to %s, which is reserved for the scaffold marker", entry.OutputPath, entry.TemplatePath, spec.MarkerFileName)
+	}
+	return rendered, nil
+}
+
+// formatGo gofmts a rendered Go file. A syntax error is reported at its line in
+// the rendered output, which need not be the same line in the template, along
+// with the template that produced it.
+func formatGo(entry ManifestEntry, content []byte) ([]byte, error) {
+	formatted, err := format.Source(content)
+	if err == nil {
+		return formatted, nil
+	}
+
+	var list scanner.ErrorList
+	if errors.As(err, &list) && len(list) > 0 {
+		first := list[0]
+		line := ""
+		if lines := strings.Split(string(content), "\n"); first.Pos.Line > 0 && first.Pos.Line <= len(lines) {
+			line = strings.TrimSpace(lines[first.Pos.Line-1])
+		}
+		return nil, fmt.Errorf("template %s renders invalid Go for %s: rendered output line %d: %s: %q", entry.TemplatePath, entry.OutputPath, first.Pos.Line, first.Msg, line)
+	}
+	return nil, fmt.Errorf("template %s renders invalid Go for %s: %w", entry.TemplatePath, entry.OutputPath, err)
+}
+
+func writeToDir(out outfs.FS, baseDir string, file PlannedFile) error {
+	target := path.Join(baseDir, path.Clean(file.OutputPath))
+	if err := out.MkdirAll(path.Dir(target), 0o755); err != nil {
+		return fmt.Errorf("create output directory for %s: %w", file.OutputPath, err)
+	}
+
+	if err := out.WriteFile(target, file.Content, file.mode()); err != nil {
+		return fmt.Errorf("write output file %s: %w", file.OutputPath, err)
 	}
 
 	return nil
@@ -17,16 +17,17 @@ const updateGoldenEnv = "UPDATE_GOLDEN"
 func TestGenerateGoldenHelloAPI(t *testing.T) {
 	outputDir := filepath.Join(t.TempDir(), "hello-api")
 	project := spec.ProjectSpec{
-		Name:     "hello-api",
-		Module:   "github.com/example/hello-api",
-		Dir:      outputDir,
-		HTTPPort: 8080,
+		Name:      "hello-api",
+		Module:    "github.com/example/hello-api",
+		Dir:       outputDir,
+		HTTPPort:  8080,
+		GoVersion: "1.22.0",
 	}
 
 	if err := project.Validate(); err != nil {
 		t.Fatalf("validate spec: %v", err)
 	}
-	if err := Generate(project, "0.1.0"); err != nil {
+	if _, err := Generate(project, Options{Version: "0.1.0", Verify: true}); err != nil {
 		t.Fatalf("generate: %v", err)
 	}
 
@@ -0,0 +1,1028 @@
+package generator
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"testing/fstest"
+
+	"github.com/ridzuwary/gokit-scaffold/internal/outfs"
+	"github.com/ridzuwary/gokit-scaffold/internal/spec"
+)
+
+func TestWritePlanRollsBackOnFailure(t *testing.T) {
+	root := t.TempDir()
+	target := filepath.Join(root, "nested", "svc")
+
+	plan := RenderPlan{
+		Dir: target,
+		Files: []PlannedFile{
+			{OutputPath: "a", Content: []byte("file")},
+			{OutputPath: "a/b", Content: []byte("cannot nest under a file")},
+		},
+	}
+
+	if _, err := writePlan(plan, ConflictFail); err == nil {
+		t.Fatalf("expected write error, got nil")
+	}
+
+	entries, err := os.ReadDir(root)
+	if err != nil {
+		t.Fatalf("read root: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Fatalf("expected no debris under %s, found %d entries (first: %s)", root, len(entries), entries[0].Name())
+	}
+}
+
+func TestWritePlanFillsExistingEmptyDir(t *testing.T) {
+	target := filepath.Join(t.TempDir(), "svc")
+	if err := os.Mkdir(target, 0o755); err != nil {
+		t.Fatalf("mkdir target: %v", err)
+	}
+
+	plan := RenderPlan{
+		Dir:   target,
+		Files: []PlannedFile{{OutputPath: "internal/x.go", Content: []byte("package x\n")}},
+	}
+	if _, err := writePlan(plan, ConflictFail); err != nil {
+		t.Fatalf("write plan: %v", err)
+	}
+
+	content, err := os.ReadFile(filepath.Join(target, "internal", "x.go"))
+	if err != nil {
+		t.Fatalf("read output: %v", err)
+	}
+	if string(content) != "package x\n" {
+		t.Fatalf("unexpected content %q", content)
+	}
+
+	entries, err := os.ReadDir(filepath.Dir(target))
+	if err != nil {
+		t.Fatalf("read parent: %v", err)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected staging directory to be gone, parent has %d entries", len(entries))
+	}
+}
+
+func TestWritePlanConflictPolicies(t *testing.T) {
+	plan := func(dir string) RenderPlan {
+		return RenderPlan{
+			Dir: dir,
+			Files: []PlannedFile{
+				{OutputPath: "LICENSE", Content: []byte("generated license")},
+				{OutputPath: "README.md", Content: []byte("generated readme")},
+			},
+		}
+	}
+	seed := func(t *testing.T) string {
+		t.Helper()
+		dir := t.TempDir()
+		if err := os.WriteFile(filepath.Join(dir, "LICENSE"), []byte("user license"), 0o644); err != nil {
+			t.Fatalf("seed LICENSE: %v", err)
+		}
+		return dir
+	}
+	read := func(t *testing.T, path string) string {
+		t.Helper()
+		content, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatalf("read %s: %v", path, err)
+		}
+		return string(content)
+	}
+
+	t.Run("fail leaves target untouched", func(t *testing.T) {
+		dir := seed(t)
+		if _, err := writePlan(plan(dir), ConflictFail); err == nil {
+			t.Fatalf("expected conflict error, got nil")
+		}
+		if _, err := os.Stat(filepath.Join(dir, "README.md")); !os.IsNotExist(err) {
+			t.Fatalf("expected README.md to not be written, stat err: %v", err)
+		}
+	})
+
+	t.Run("skip keeps existing files", func(t *testing.T) {
+		dir := seed(t)
+		report, err := writePlan(plan(dir), ConflictSkip)
+		if err != nil {
+			t.Fatalf("write plan: %v", err)
+		}
+		if got := read(t, filepath.Join(dir, "LICENSE")); got != "user license" {
+			t.Fatalf("expected LICENSE to be kept, got %q", got)
+		}
+		if got := read(t, filepath.Join(dir, "README.md")); got != "generated readme" {
+			t.Fatalf("expected README.md to be created, got %q", got)
+		}
+		if report.Count(ActionSkipped) != 1 || report.Count(ActionCreated) != 1 {
+			t.Fatalf("unexpected report: %+v", report.Files)
+		}
+	})
+
+	t.Run("overwrite replaces existing files", func(t *testing.T) {
+		dir := seed(t)
+		if _, err := writePlan(plan(dir), ConflictOverwrite); err != nil {
+			t.Fatalf("write plan: %v", err)
+		}
+		if got := read(t, filepath.Join(dir, "LICENSE")); got != "generated license" {
+			t.Fatalf("expected LICENSE to be overwritten, got %q", got)
+		}
+	})
+
+	t.Run("backup keeps a .orig copy", func(t *testing.T) {
+		dir := seed(t)
+		report, err := writePlan(plan(dir), ConflictBackup)
+		if err != nil {
+			t.Fatalf("write plan: %v", err)
+		}
+		if got := read(t, filepath.Join(dir, "LICENSE")); got != "generated license" {
+			t.Fatalf("expected LICENSE to be replaced, got %q", got)
+		}
+		if got := read(t, filepath.Join(dir, "LICENSE.orig")); got != "user license" {
+			t.Fatalf("expected LICENSE.orig to hold the user version, got %q", got)
+		}
+		if report.Files[0].BackupPath != "LICENSE.orig" {
+			t.Fatalf("expected backup path in report, got %+v", report.Files[0])
+		}
+	})
+}
+
+func TestDryRunPreviewsConflicts(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hello-api")
+	plan, err := Plan(spec.ProjectSpec{
+		Name:      "hello-api",
+		Module:    "github.com/example/hello-api",
+		Dir:       dir,
+		HTTPPort:  8080,
+		GoVersion: "1.22.0",
+	}, "0.1.0")
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+
+	report, err := DryRun(plan, ConflictFail)
+	if err != nil {
+		t.Fatalf("dry run into a missing directory: %v", err)
+	}
+	if report.Count(ActionCreated) != len(plan.Files) {
+		t.Fatalf("expected every file to be created: %+v", report.Files)
+	}
+	if _, err := os.Stat(dir); !errors.Is(err, os.ErrNotExist) {
+		t.Fatalf("dry run created %s: %v", dir, err)
+	}
+
+	if err := os.MkdirAll(filepath.Join(dir, "cmd", "server"), 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for rel, content := range map[string]string{"go.mod": "module local\n", "README.md.orig": "old backup\n", "cmd/server/main.go": "package main\n"} {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(rel)), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# mine\n"), 0o644); err != nil {
+		t.Fatalf("write README.md: %v", err)
+	}
+
+	if _, err := DryRun(plan, ConflictFail); err == nil || !strings.Contains(err.Error(), "go.mod") {
+		t.Fatalf("expected the fail policy to report go.mod, got %v", err)
+	}
+	if _, err := DryRun(plan, ConflictBackup); err == nil || !strings.Contains(err.Error(), "README.md.orig already exists") {
+		t.Fatalf("expected the existing backup to be reported, got %v", err)
+	}
+	report, err = DryRun(plan, ConflictSkip)
+	if err != nil {
+		t.Fatalf("dry run with skip: %v", err)
+	}
+	if report.Count(ActionSkipped) != 3 || report.Count(ActionCreated) != len(plan.Files)-3 {
+		t.Fatalf("unexpected actions: %+v", report.Files)
+	}
+	if content, _ := os.ReadFile(filepath.Join(dir, "go.mod")); string(content) != "module local\n" {
+		t.Fatalf("dry run modified go.mod: %q", content)
+	}
+	entries, _ := os.ReadDir(dir)
+	if len(entries) != 4 {
+		t.Fatalf("dry run changed the directory: %d entries", len(entries))
+	}
+}
+
+func TestWriteToMemory(t *testing.T) {
+	out := outfs.NewMem()
+	if err := out.WriteFile("LICENSE", []byte("user license"), 0o644); err != nil {
+		t.Fatalf("seed LICENSE: %v", err)
+	}
+
+	plan := RenderPlan{
+		Dir: "svc",
+		Files: []PlannedFile{
+			{OutputPath: "LICENSE", Content: []byte("generated license")},
+			{OutputPath: "scripts/run.sh", Content: []byte("#!/bin/sh\n"), Mode: 0o755},
+		},
+	}
+	report, err := WriteTo(out, plan, ConflictBackup)
+	if err != nil {
+		t.Fatalf("write: %v", err)
+	}
+	if report.Dir != "svc" || report.Count(ActionBackedUp) != 1 || report.Count(ActionCreated) != 1 {
+		t.Fatalf("unexpected report: %+v", report)
+	}
+
+	want := map[string]string{
+		"LICENSE":        "generated license",
+		"LICENSE.orig":   "user license",
+		"scripts/run.sh": "#!/bin/sh\n",
+	}
+	if got := out.Paths(); len(got) != len(want) {
+		t.Fatalf("unexpected files (staging left behind?): %v", got)
+	}
+	for name, content := range want {
+		got, err := fs.ReadFile(out, name)
+		if err != nil || string(got) != content {
+			t.Fatalf("%s: got %q, %v; want %q", name, got, err, content)
+		}
+	}
+	if info, err := out.Lstat("scripts/run.sh"); err != nil || info.Mode().Perm() != 0o755 {
+		t.Fatalf("scripts/run.sh: mode %v, %v", info.Mode(), err)
+	}
+
+	broken := RenderPlan{Files: []PlannedFile{
+		{OutputPath: "a", Content: []byte("file")},
+		{OutputPath: "a/b", Content: []byte("cannot nest under a file")},
+	}}
+	before := out.Paths()
+	if _, err := WriteTo(out, broken, ConflictFail); err == nil {
+		t.Fatalf("expected write error, got nil")
+	}
+	if after := out.Paths(); len(after) != len(before) {
+		t.Fatalf("failed write left %v behind, had %v", after, before)
+	}
+}
+
+func TestPlanWithTemplateDir(t *testing.T) {
+	packDir := filepath.Join(t.TempDir(), "our-service")
+	files := map[string]string{
+		"pack.json": `{"entries": [
+			{"template": "main.go.tmpl", "output": "cmd/main.go"},
+			{"template": "docs/README.md.tmpl", "output": "README.md"}
+		]}`,
+		"main.go.tmpl":        "package main // {{ .Module }}\n",
+		"docs/README.md.tmpl": "# {{ .Name }}\n",
+	}
+	for rel, content := range files {
+		path := filepath.Join(packDir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", path, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+
+	project := spec.ProjectSpec{
+		Name:        "hello-api",
+		Module:      "github.com/example/hello-api",
+		Dir:         filepath.Join(t.TempDir(), "hello-api"),
+		HTTPPort:    8080,
+		TemplateDir: packDir,
+	}
+	plan, err := Plan(project, "0.1.0")
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+
+	if plan.TemplatePack != "our-service" {
+		t.Fatalf("template pack = %q, want our-service", plan.TemplatePack)
+	}
+	got := map[string]string{}
+	for _, file := range plan.Files {
+		got[file.OutputPath] = string(file.Content)
+	}
+	if got["README.md"] != "# hello-api\n" {
+		t.Fatalf("unexpected README.md: %q", got["README.md"])
+	}
+
+	var marker spec.Marker
+	if err := json.Unmarshal([]byte(got[spec.MarkerFileName]), &marker); err != nil {
+		t.Fatalf("parse marker: %v", err)
+	}
+	if marker.TemplatePack != "our-service" {
+		t.Fatalf("marker template_pack = %q, want our-service", marker.TemplatePack)
+	}
+	if errs := spec.ValidateMarker(marker); len(errs) > 0 {
+		t.Fatalf("generated marker is invalid: %v", errs)
+	}
+}
+
+func TestPlanWithExtendedPack(t *testing.T) {
+	root := t.TempDir()
+	writePackFiles(t, filepath.Join(root, "acme-http"), map[string]string{
+		"pack.json": `{
+			"extends": "service-http",
+			"entries": [
+				{"template": "logging.go.tmpl", "output": "internal/logging/logging.go"},
+				{"template": "auth.go.tmpl", "output": "internal/auth/auth.go"},
+				{"output": "README.md", "delete": true}
+			],
+			"variables": [{"name": "shutdown_timeout_seconds", "type": "int", "default": 30}]
+		}`,
+		"logging.go.tmpl": "package logging\n\nimport (\n\t\"log\"\n\t\"os\"\n)\n\nfunc New() *log.Logger {\n\treturn log.New(os.Stderr, \"{{ .Name }} \", log.LstdFlags)\n}\n",
+		"auth.go.tmpl":    "package auth\n\nconst Realm = {{ .Name | quote }}\n",
+	})
+	writePackFiles(t, filepath.Join(root, "acme-billing"), map[string]string{
+		"pack.json":    `{"extends": "../acme-http", "entries": [{"template": "auth.go.tmpl", "output": "internal/auth/auth.go"}]}`,
+		"auth.go.tmpl": "package auth\n\nconst Realm = \"billing\"\n",
+	})
+
+	project := spec.ProjectSpec{
+		Name:        "hello-api",
+		Module:      "github.com/example/hello-api",
+		Dir:         filepath.Join(t.TempDir(), "hello-api"),
+		HTTPPort:    8080,
+		TemplateDir: filepath.Join(root, "acme-billing"),
+	}
+	plan, err := Plan(project, "0.1.0")
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if err := Verify(plan); err != nil {
+		t.Fatalf("verify: %v", err)
+	}
+
+	got := map[string]string{}
+	for _, file := range plan.Files {
+		got[file.OutputPath] = string(file.Content)
+	}
+	if _, ok := got["README.md"]; ok {
+		t.Fatalf("expected README.md to be deleted by acme-http")
+	}
+	if !strings.Contains(got["internal/logging/logging.go"], `"hello-api "`) {
+		t.Fatalf("expected logging.go from acme-http, got:\n%s", got["internal/logging/logging.go"])
+	}
+	if !strings.Contains(got["internal/auth/auth.go"], `"billing"`) {
+		t.Fatalf("expected auth.go from acme-billing, got:\n%s", got["internal/auth/auth.go"])
+	}
+	if !strings.Contains(got["cmd/server/main.go"], "30*time.Second") {
+		t.Fatalf("expected the redeclared variable default, got:\n%s", got["cmd/server/main.go"])
+	}
+
+	var marker spec.Marker
+	if err := json.Unmarshal([]byte(got[spec.MarkerFileName]), &marker); err != nil {
+		t.Fatalf("parse marker: %v", err)
+	}
+	if want := []string{"service-http", "acme-http", "acme-billing"}; strings.Join(marker.TemplateLayers, ",") != strings.Join(want, ",") {
+		t.Fatalf("marker template_layers = %v, want %v", marker.TemplateLayers, want)
+	}
+	if errs := spec.ValidateMarker(marker); len(errs) > 0 {
+		t.Fatalf("generated marker is invalid: %v", errs)
+	}
+}
+
+func TestLoadPackDirExtendsErrors(t *testing.T) {
+	cases := []struct {
+		name  string
+		packs map[string]map[string]string
+		want  string
+	}{
+		{
+			name: "cycle",
+			packs: map[string]map[string]string{
+				"a": {"pack.json": `{"extends": "../b", "entries": [{"output": "go.mod", "delete": true}]}`},
+				"b": {"pack.json": `{"extends": "../a", "entries": [{"output": "go.mod", "delete": true}]}`},
+			},
+			want: "extends itself",
+		},
+		{
+			name: "delete without base",
+			packs: map[string]map[string]string{
+				"a": {"pack.json": `{"entries": [{"output": "go.mod", "delete": true}]}`},
+			},
+			want: "delete only applies to a pack that extends another",
+		},
+		{
+			name: "delete unknown output",
+			packs: map[string]map[string]string{
+				"a": {"pack.json": `{"extends": "service-http", "entries": [{"output": "Makefile", "delete": true}]}`},
+			},
+			want: "delete matches no entry of service-http",
+		},
+		{
+			name: "unknown base",
+			packs: map[string]map[string]string{
+				"a": {"pack.json": `{"extends": "service-grpc", "entries": []}`},
+			},
+			want: "unknown template pack: service-grpc",
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			root := t.TempDir()
+			for name, files := range tc.packs {
+				writePackFiles(t, filepath.Join(root, name), files)
+			}
+			_, err := LoadPackDir(filepath.Join(root, "a"))
+			if err == nil || !strings.Contains(err.Error(), tc.want) {
+				t.Fatalf("error = %v, want it to contain %q", err, tc.want)
+			}
+		})
+	}
+}
+
+func TestListPacks(t *testing.T) {
+	root := t.TempDir()
+	writePackFiles(t, filepath.Join(root, "acme-http"), map[string]string{
+		"pack.json":    `{"description": "Acme HTTP service", "extends": "service-http", "entries": [{"template": "auth.go.tmpl", "output": "internal/auth/auth.go"}]}`,
+		"auth.go.tmpl": "package auth\n",
+	})
+	writePackFiles(t, filepath.Join(root, "notes"), map[string]string{"README.md": "not a pack\n"})
+
+	packs, err := ListPacks(root)
+	if err != nil {
+		t.Fatalf("list packs: %v", err)
+	}
+	var names []string
+	for _, pack := range packs {
+		names = append(names, pack.Name+"@"+pack.Origin)
+	}
+	want := []string{"service-http@" + EmbeddedOrigin, "acme-http@" + filepath.Join(root, "acme-http")}
+	if strings.Join(names, ",") != strings.Join(want, ",") {
+		t.Fatalf("packs = %v, want %v", names, want)
+	}
+
+	pack, err := LoadPack("acme-http", root)
+	if err != nil {
+		t.Fatalf("load acme-http from search dir: %v", err)
+	}
+	if strings.Join(pack.Layers, ",") != "service-http,acme-http" {
+		t.Fatalf("layers = %v", pack.Layers)
+	}
+	if _, err := LoadPack("acme-http", ""); err == nil {
+		t.Fatalf("expected acme-http to be unknown without a search dir")
+	}
+}
+
+func writePackFiles(t *testing.T, dir string, files map[string]string) {
+	t.Helper()
+	for rel, content := range files {
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", path, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+}
+
+func TestLoadPackDirRejectsEscapingOutput(t *testing.T) {
+	packDir := t.TempDir()
+	manifest := `{"entries": [{"template": "x.tmpl", "output": "../x"}]}`
+	if err := os.WriteFile(filepath.Join(packDir, "pack.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write pack.json: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(packDir, "x.tmpl"), []byte("x"), 0o644); err != nil {
+		t.Fatalf("write template: %v", err)
+	}
+
+	if _, err := LoadPackDir(packDir); err == nil {
+		t.Fatalf("expected traversal output path to be rejected")
+	}
+}
+
+func TestPlanPackVariables(t *testing.T) {
+	project := spec.ProjectSpec{
+		Name:     "hello-api",
+		Module:   "github.com/example/hello-api",
+		Dir:      filepath.Join(t.TempDir(), "hello-api"),
+		HTTPPort: 8080,
+		Vars:     map[string]string{"shutdown_timeout_seconds": "30"},
+	}
+
+	plan, err := Plan(project, "0.1.0")
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	for _, file := range plan.Files {
+		if file.OutputPath == "cmd/server/main.go" && !bytes.Contains(file.Content, []byte("30*time.Second")) {
+			t.Fatalf("expected shutdown timeout override in main.go, got:\n%s", file.Content)
+		}
+	}
+
+	project.Vars = map[string]string{"shutdown_timeout_seconds": "soon"}
+	if _, err := Plan(project, "0.1.0"); err == nil {
+		t.Fatalf("expected type error for non-int variable")
+	}
+
+	project.Vars = map[string]string{"unknown": "x"}
+	if _, err := Plan(project, "0.1.0"); err == nil {
+		t.Fatalf("expected error for undeclared variable")
+	}
+}
+
+func TestPlanFeatures(t *testing.T) {
+	project := spec.ProjectSpec{
+		Name:     "hello-api",
+		Module:   "github.com/example/hello-api",
+		Dir:      filepath.Join(t.TempDir(), "hello-api"),
+		HTTPPort: 8080,
+	}
+	outputs := func(t *testing.T, features map[string]bool) map[string][]byte {
+		t.Helper()
+		project.Features = features
+		plan, err := Plan(project, "0.1.0")
+		if err != nil {
+			t.Fatalf("plan: %v", err)
+		}
+		files := map[string][]byte{}
+		for _, file := range plan.Files {
+			files[file.OutputPath] = file.Content
+		}
+		return files
+	}
+
+	defaults := outputs(t, nil)
+	for _, rel := range []string{"Dockerfile", "internal/httpserver/metrics.go", "internal/httpserver/pprof.go"} {
+		if _, ok := defaults[rel]; ok {
+			t.Fatalf("expected %s to be generated only when its feature is enabled", rel)
+		}
+	}
+
+	withMetrics := outputs(t, map[string]bool{"metrics": true})
+	if _, ok := withMetrics["internal/httpserver/metrics.go"]; !ok {
+		t.Fatalf("expected metrics.go with --with metrics")
+	}
+	if !bytes.Contains(withMetrics["internal/httpserver/server.go"], []byte("registerMetrics(mux)")) {
+		t.Fatalf("expected server.go to register metrics, got:\n%s", withMetrics["internal/httpserver/server.go"])
+	}
+
+	var marker spec.Marker
+	if err := json.Unmarshal(withMetrics[spec.MarkerFileName], &marker); err != nil {
+		t.Fatalf("parse marker: %v", err)
+	}
+	if len(marker.Spec.Features) != 1 || marker.Spec.Features[0] != "metrics" {
+		t.Fatalf("marker features = %v, want [metrics]", marker.Spec.Features)
+	}
+	if _, ok := marker.Files["internal/httpserver/pprof.go"]; ok {
+		t.Fatalf("marker should not record files of disabled features")
+	}
+
+	project.Features = map[string]bool{"graphql": true}
+	if _, err := Plan(project, "0.1.0"); err == nil {
+		t.Fatalf("expected error for unknown feature")
+	}
+}
+
+func TestRenderFile(t *testing.T) {
+	project := spec.ProjectSpec{
+		Name:      "hello-api",
+		Module:    "github.com/example/hello-api",
+		HTTPPort:  8080,
+		GoVersion: "1.22.0",
+	}
+	pack, err := EmbeddedPack(spec.TemplatePackName)
+	if err != nil {
+		t.Fatalf("load pack: %v", err)
+	}
+	plan, err := planPack(pack, project, "0.1.0")
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	planned := map[string][]byte{}
+	for _, file := range plan.Files {
+		planned[file.OutputPath] = file.Content
+	}
+
+	for _, rel := range []string{"internal/httpserver/server.go", "./go.mod", spec.MarkerFileName} {
+		file, err := RenderFile(pack, project, rel, "0.1.0")
+		if err != nil {
+			t.Fatalf("render %s: %v", rel, err)
+		}
+		if !bytes.Equal(file.Content, planned[file.OutputPath]) {
+			t.Fatalf("render %s differs from the planned file:\n%s", rel, file.Content)
+		}
+	}
+
+	if _, err := RenderFile(pack, project, "internal/httpserver/pprof.go", "0.1.0"); err == nil || !strings.Contains(err.Error(), "--with pprof") {
+		t.Fatalf("expected a hint to enable pprof, got %v", err)
+	}
+	if _, err := RenderFile(pack, project, "Makefile", "0.1.0"); err == nil {
+		t.Fatalf("expected an error for an output the pack does not generate")
+	}
+}
+
+func TestPlanFromFormatsGoOutputs(t *testing.T) {
+	fsys := fstest.MapFS{
+		"pack/main.go.tmpl":   {Data: []byte("package main\nfunc main()  {\n\tprintln( {{ quote .Name }} )\n}\n")},
+		"pack/broken.go.tmpl": {Data: []byte("package main\n\nfunc {{ .Name }}() {}\n")},
+		"pack/notes.txt.tmpl": {Data: []byte("keep   {{ .Name }}  as is\n")},
+	}
+	data := templateData{Name: "hello-api"}
+
+	tests := []struct {
+		name    string
+		entry   ManifestEntry
+		want    string
+		wantErr []string
+	}{
+		{
+			name:  "go output is gofmt'd",
+			entry: ManifestEntry{TemplatePath: "pack/main.go.tmpl", OutputPath: "main.go"},
+			want:  "package main\n\nfunc main() {\n\tprintln(\"hello-api\")\n}\n",
+		},
+		{
+			name:  "non-go output is untouched",
+			entry: ManifestEntry{TemplatePath: "pack/notes.txt.tmpl", OutputPath: "notes.txt"},
+			want:  "keep   hello-api  as is\n",
+		},
+		{
+			name:    "invalid go names template and line",
+			entry:   ManifestEntry{TemplatePath: "pack/broken.go.tmpl", OutputPath: "main.go"},
+			wantErr: []string{"template pack/broken.go.tmpl ", "rendered output line 3:", "func hello-api() {}"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := planFrom(fsys, []ManifestEntry{tt.entry}, data, t.TempDir())
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Fatalf("error %q does not mention %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("plan: %v", err)
+			}
+			if got := string(plan.Files[0].Content); got != tt.want {
+				t.Fatalf("content = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenerateEntryModes(t *testing.T) {
+	packDir := filepath.Join(t.TempDir(), "scripted")
+	files := map[string]string{
+		"pack.json": `{"entries": [
+			{"template": "dev.sh.tmpl", "output": "scripts/dev.sh", "mode": "0755"},
+			{"template": "env.tmpl", "output": ".env.local", "mode": "0600"},
+			{"template": "README.md.tmpl", "output": "README.md"}
+		]}`,
+		"dev.sh.tmpl":    "#!/bin/sh\ngo run ./cmd/{{ .Name }}\n",
+		"env.tmpl":       "PORT={{ .HTTPPort }}\n",
+		"README.md.tmpl": "# {{ .Name }}\n",
+	}
+	if err := os.MkdirAll(packDir, 0o755); err != nil {
+		t.Fatalf("mkdir %s: %v", packDir, err)
+	}
+	for rel, content := range files {
+		if err := os.WriteFile(filepath.Join(packDir, rel), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+
+	dir := filepath.Join(t.TempDir(), "hello-api")
+	project := spec.ProjectSpec{
+		Name:        "hello-api",
+		Module:      "github.com/example/hello-api",
+		Dir:         dir,
+		HTTPPort:    8080,
+		TemplateDir: packDir,
+	}
+	if _, err := Generate(project, Options{Version: "0.1.0"}); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	marker, err := spec.ReadMarker(filepath.Join(dir, spec.MarkerFileName))
+	if err != nil {
+		t.Fatalf("read marker: %v", err)
+	}
+	wantModes := map[string]string{"scripts/dev.sh": "0755", ".env.local": "0600"}
+	if len(marker.Modes) != len(wantModes) {
+		t.Fatalf("marker modes = %v, want %v", marker.Modes, wantModes)
+	}
+	for rel, want := range wantModes {
+		if marker.Modes[rel] != want {
+			t.Fatalf("marker mode for %s = %q, want %q", rel, marker.Modes[rel], want)
+		}
+	}
+	if errs := spec.ValidateMarker(marker); len(errs) > 0 {
+		t.Fatalf("generated marker is invalid: %v", errs)
+	}
+
+	if runtime.GOOS != "windows" {
+		for rel, want := range map[string]os.FileMode{"scripts/dev.sh": 0o755, ".env.local": 0o600, "README.md": 0o644} {
+			info, err := os.Stat(filepath.Join(dir, rel))
+			if err != nil {
+				t.Fatalf("stat %s: %v", rel, err)
+			}
+			if got := info.Mode().Perm(); got != want {
+				t.Fatalf("mode of %s = %04o, want %04o", rel, got, want)
+			}
+		}
+	}
+
+	manifest := `{"entries": [{"template": "README.md.tmpl", "output": "README.md", "mode": "rwx"}]}`
+	if err := os.WriteFile(filepath.Join(packDir, "pack.json"), []byte(manifest), 0o644); err != nil {
+		t.Fatalf("write pack.json: %v", err)
+	}
+	if _, err := LoadPackDir(packDir); err == nil {
+		t.Fatalf("expected invalid mode to be rejected")
+	}
+}
+
+func TestValidateDirectoryPackRequired(t *testing.T) {
+	packDir := filepath.Join(t.TempDir(), "scripted")
+	files := map[string]string{
+		"pack.json": `{"entries": [
+			{"template": "dev.sh.tmpl", "output": "scripts/dev.sh", "mode": "0755"},
+			{"template": "README.md.tmpl", "output": "README.md"}
+		], "required": ["README.md"]}`,
+		"dev.sh.tmpl":    "#!/bin/sh\ngo run ./cmd/{{ .Name }}\n",
+		"README.md.tmpl": "# {{ .Name }}\n",
+	}
+	if err := os.MkdirAll(packDir, 0o755); err != nil {
+		t.Fatalf("mkdir %s: %v", packDir, err)
+	}
+	for rel, content := range files {
+		if err := os.WriteFile(filepath.Join(packDir, rel), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+
+	dir := filepath.Join(t.TempDir(), "hello-api")
+	project := spec.ProjectSpec{
+		Name:        "hello-api",
+		Module:      "github.com/example/hello-api",
+		Dir:         dir,
+		HTTPPort:    8080,
+		TemplateDir: packDir,
+	}
+	if _, err := Generate(project, Options{Version: "0.1.0"}); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	// validate must not need the pack directory.
+	if err := os.RemoveAll(packDir); err != nil {
+		t.Fatalf("remove pack: %v", err)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "scripts", "dev.sh")); err != nil {
+		t.Fatalf("remove dev.sh: %v", err)
+	}
+	if errs := spec.ValidateScaffoldDir(dir); len(errs) > 0 {
+		t.Fatalf("expected deleting an optional file to keep the scaffold valid, got %v", errs)
+	}
+
+	if err := os.Remove(filepath.Join(dir, "README.md")); err != nil {
+		t.Fatalf("remove README.md: %v", err)
+	}
+	if errs := spec.ValidateScaffoldDir(dir); len(errs) != 1 || !strings.Contains(errs[0].Error(), "README.md") {
+		t.Fatalf("expected only the required README.md to fail validation, got %v", errs)
+	}
+	drift, err := spec.ScaffoldDrift(dir)
+	if err != nil {
+		t.Fatalf("scaffold drift: %v", err)
+	}
+	for _, file := range drift {
+		if file.State != spec.FileDeleted {
+			t.Fatalf("expected %s to be reported as deleted, got %s", file.Path, file.State)
+		}
+	}
+	if len(drift) != 2 {
+		t.Fatalf("expected both deleted files in the drift report, got %+v", drift)
+	}
+}
+
+func TestPlanCopyAndDelimsEntries(t *testing.T) {
+	binary := []byte{0x89, 'P', 'N', 'G', 0x00, 0xff, '{', '{', ' ', '.', 'N', 'a', 'm', 'e', ' ', '}', '}'}
+	packDir := t.TempDir()
+	files := map[string][]byte{
+		"pack.json": []byte(`{"entries": [
+			{"template": "logo.png", "output": "static/logo.png", "copy": true},
+			{"template": "page.html", "output": "web/page.html", "copy": true},
+			{"template": "ci.yml.tmpl", "output": ".github/workflows/ci.yml", "delims": ["[[", "]]"]}
+		]}`),
+		"logo.png":    binary,
+		"page.html":   []byte("<title>{{ .Title }}</title>\n"),
+		"ci.yml.tmpl": []byte("name: [[ .Name ]]\nrun: echo ${{ github.sha }}\n"),
+	}
+	for rel, content := range files {
+		if err := os.WriteFile(filepath.Join(packDir, rel), content, 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+
+	plan, err := Plan(spec.ProjectSpec{
+		Name:        "hello-api",
+		Module:      "github.com/example/hello-api",
+		Dir:         filepath.Join(t.TempDir(), "hello-api"),
+		HTTPPort:    8080,
+		TemplateDir: packDir,
+	}, "0.1.0")
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	got := map[string][]byte{}
+	for _, file := range plan.Files {
+		got[file.OutputPath] = file.Content
+	}
+
+	if !bytes.Equal(got["static/logo.png"], binary) {
+		t.Fatalf("binary asset was not copied verbatim: %q", got["static/logo.png"])
+	}
+	if string(got["web/page.html"]) != "<title>{{ .Title }}</title>\n" {
+		t.Fatalf("copy entry was rendered: %q", got["web/page.html"])
+	}
+	if want := "name: hello-api\nrun: echo ${{ github.sha }}\n"; string(got[".github/workflows/ci.yml"]) != want {
+		t.Fatalf("ci.yml = %q, want %q", got[".github/workflows/ci.yml"], want)
+	}
+
+	for _, manifest := range []string{
+		`{"entries": [{"template": "page.html", "output": "page.html", "copy": true, "delims": ["[[", "]]"]}]}`,
+		`{"entries": [{"template": "ci.yml.tmpl", "output": "ci.yml", "delims": ["[["]}]}`,
+	} {
+		if err := os.WriteFile(filepath.Join(packDir, "pack.json"), []byte(manifest), 0o644); err != nil {
+			t.Fatalf("write pack.json: %v", err)
+		}
+		if _, err := LoadPackDir(packDir); err == nil {
+			t.Fatalf("expected manifest to be rejected: %s", manifest)
+		}
+	}
+}
+
+func TestPlanTemplatedOutputPaths(t *testing.T) {
+	packDir := t.TempDir()
+	files := map[string]string{
+		"pack.json": `{
+			"entries": [
+				{"template": "main.go.tmpl", "output": "cmd/{{ .Name }}/main.go"},
+				{"template": "unit.tmpl", "output": "{{ .Vars.deploy_dir }}/{{ .Name }}.service"}
+			],
+			"variables": [{"name": "deploy_dir", "type": "string", "default": "deploy"}]
+		}`,
+		"main.go.tmpl": "package main\n\nfunc main() {}\n",
+		"unit.tmpl":    "[Service]\nExecStart=/usr/local/bin/{{ .Name }}\n",
+	}
+	for rel, content := range files {
+		if err := os.WriteFile(filepath.Join(packDir, rel), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+	project := spec.ProjectSpec{
+		Name:        "hello-api",
+		Module:      "github.com/example/hello-api",
+		Dir:         filepath.Join(t.TempDir(), "hello-api"),
+		HTTPPort:    8080,
+		TemplateDir: packDir,
+	}
+
+	pack, err := LoadPackDir(packDir)
+	if err != nil {
+		t.Fatalf("load pack: %v", err)
+	}
+	paths, err := OutputPaths(pack, project)
+	if err != nil {
+		t.Fatalf("output paths: %v", err)
+	}
+	want := []string{spec.MarkerFileName, "cmd/hello-api/main.go", "deploy/hello-api.service"}
+	if strings.Join(paths, ",") != strings.Join(want, ",") {
+		t.Fatalf("output paths = %v, want %v", paths, want)
+	}
+
+	tests := []struct {
+		name      string
+		deployDir string
+		wantErr   string
+	}{
+		{name: "cleaned", deployDir: "ops/./deploy/", wantErr: ""},
+		{name: "parent traversal", deployDir: "../..", wantErr: "outside the target directory"},
+		{name: "absolute", deployDir: "/etc/systemd", wantErr: "outside the target directory"},
+		{name: "backslash", deployDir: `..\..`, wantErr: "outside the target directory"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			project.Vars = map[string]string{"deploy_dir": tt.deployDir}
+			_, err := Plan(project, "0.1.0")
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("plan: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestLoadPackDirRequiredTemplatedPaths(t *testing.T) {
+	tests := []struct {
+		name     string
+		manifest string
+		wantErr  string
+	}{
+		{
+			name:     "default delims",
+			manifest: `{"entries": [{"template": "main.go.tmpl", "output": "cmd/{{ .Name }}/main.go"}], "required": ["cmd/{{ .Name }}/main.go"]}`,
+			wantErr:  "templated output paths cannot be required",
+		},
+		{
+			name:     "custom delims",
+			manifest: `{"entries": [{"template": "main.go.tmpl", "output": "cmd/[[ .Name ]]/main.go", "delims": ["[[", "]]"]}], "required": ["cmd/[[ .Name ]]/main.go"]}`,
+			wantErr:  "templated output paths cannot be required",
+		},
+		{
+			name:     "literal braces under custom delims",
+			manifest: `{"entries": [{"template": "main.go.tmpl", "output": "docs/{{ raw }}.md", "delims": ["[[", "]]"]}], "required": ["docs/{{ raw }}.md"]}`,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			packDir := t.TempDir()
+			files := map[string]string{
+				"pack.json":    tt.manifest,
+				"main.go.tmpl": "package main\n\nfunc main() {}\n",
+			}
+			for rel, content := range files {
+				if err := os.WriteFile(filepath.Join(packDir, rel), []byte(content), 0o644); err != nil {
+					t.Fatalf("write %s: %v", rel, err)
+				}
+			}
+
+			_, err := LoadPackDir(packDir)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("load pack: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("error = %v, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPlanGoVersion(t *testing.T) {
+	project := spec.ProjectSpec{
+		Name:     "hello-api",
+		Module:   "github.com/example/hello-api",
+		Dir:      filepath.Join(t.TempDir(), "hello-api"),
+		HTTPPort: 8080,
+	}
+	outputs := func(t *testing.T, goVersion string) map[string][]byte {
+		t.Helper()
+		project.GoVersion = goVersion
+		plan, err := Plan(project, "0.1.0")
+		if err != nil {
+			t.Fatalf("plan: %v", err)
+		}
+		files := map[string][]byte{}
+		for _, file := range plan.Files {
+			files[file.OutputPath] = file.Content
+		}
+		return files
+	}
+
+	cases := []struct {
+		goVersion string
+		routes    string
+	}{
+		{"1.21", `"/healthz"`},
+		{"1.22.3", `"GET /healthz"`},
+	}
+	for _, tc := range cases {
+		files := outputs(t, tc.goVersion)
+		if !bytes.Contains(files["go.mod"], []byte("\ngo "+tc.goVersion+"\n")) {
+			t.Fatalf("go %s: go.mod = %q", tc.goVersion, files["go.mod"])
+		}
+		if !bytes.Contains(files["internal/httpserver/health.go"], []byte(tc.routes)) {
+			t.Fatalf("go %s: expected %s route patterns, got:\n%s", tc.goVersion, tc.routes, files["internal/httpserver/health.go"])
+		}
+		var marker spec.Marker
+		if err := json.Unmarshal(files[spec.MarkerFileName], &marker); err != nil {
+			t.Fatalf("parse marker: %v", err)
+		}
+		if marker.Spec.GoVersion != tc.goVersion {
+			t.Fatalf("marker go_version = %q, want %q", marker.Spec.GoVersion, tc.goVersion)
+		}
+	}
+
+	if def := spec.DefaultGoVersion(); def != "" {
+		if files := outputs(t, ""); !bytes.Contains(files["go.mod"], []byte("\ngo "+def+"\n")) {
+			t.Fatalf("expected go.mod to default to go %s, got %q", def, files["go.mod"])
+		}
+	}
+}
@@ -0,0 +1,125 @@
+package generator
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+
+	"github.com/ridzuwary/gokit-scaffold/internal/outfs"
+	"github.com/ridzuwary/gokit-scaffold/internal/spec"
+)
+
+var majorVersionRe = regexp.MustCompile(`^v[0-9]+$`)
+
+// ReadGoMod returns the module path and go version declared by dir/go.mod. The
+// version is "" when the file has no go directive.
+func ReadGoMod(dir string) (module, goVersion string, err error) {
+	content, err := os.ReadFile(filepath.Join(dir, "go.mod"))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return "", "", fmt.Errorf("no go.mod in %s (run `go mod init` first, or use `gokit-scaffold new`)", dir)
+		}
+		return "", "", fmt.Errorf("read go.mod: %w", err)
+	}
+	module, goVersion = parseGoMod(content)
+	if module == "" {
+		return "", "", fmt.Errorf("%s has no module directive", filepath.Join(dir, "go.mod"))
+	}
+	return module, goVersion, nil
+}
+
+// NameFromModule suggests a project name for a module path: its last element
+// (skipping a /vN major version suffix) in kebab case.
+func NameFromModule(module string) string {
+	base := path.Base(module)
+	if majorVersionRe.MatchString(base) {
+		base = path.Base(path.Dir(module))
+	}
+	return kebabCase(base)
+}
+
+// Init adopts the pack's layout in an existing directory. Files that already
+// exist are left alone and reported as skipped; only the missing outputs are
+// written, and the marker records just those.
+func Init(s spec.ProjectSpec, opts Options) (Report, error) {
+	markerPath := filepath.Join(s.Dir, spec.MarkerFileName)
+	if _, err := os.Lstat(markerPath); err == nil {
+		return Report{}, fmt.Errorf("%s is already a scaffold (found %s); use `gokit-scaffold upgrade` to update it", s.Dir, spec.MarkerFileName)
+	} else if !errors.Is(err, os.ErrNotExist) {
+		return Report{}, fmt.Errorf("stat %s: %w", markerPath, err)
+	}
+
+	plan, err := Plan(s, opts.Version)
+	if err != nil {
+		return Report{}, err
+	}
+	target, err := filepath.Abs(filepath.Clean(plan.Dir))
+	if err != nil {
+		return Report{}, fmt.Errorf("resolve directory: %w", err)
+	}
+	results, err := resolveConflicts(outfs.Dir(target), plan.Files, ConflictSkip)
+	if err != nil {
+		return Report{}, err
+	}
+
+	skipped := map[string]bool{}
+	var existing []FileResult
+	for _, result := range results {
+		if result.Action == ActionSkipped {
+			skipped[result.OutputPath] = true
+			existing = append(existing, result)
+		}
+	}
+	missing := make([]PlannedFile, 0, len(plan.Files))
+	for _, file := range plan.Files {
+		if skipped[file.OutputPath] {
+			continue
+		}
+		if file.OutputPath == spec.MarkerFileName {
+			if file, err = pruneMarker(file, skipped); err != nil {
+				return Report{}, err
+			}
+		}
+		missing = append(missing, file)
+	}
+	plan.Files = missing
+
+	report, err := writePlan(plan, ConflictFail)
+	if err != nil {
+		return Report{}, err
+	}
+
+	report.Files = append(report.Files, existing...)
+	sort.Slice(report.Files, func(i, j int) bool {
+		return report.Files[i].OutputPath < report.Files[j].OutputPath
+	})
+	return report, nil
+}
+
+// pruneMarker drops the files init did not write from a rendered marker, so
+// drift detection only covers what the tool generated.
+func pruneMarker(file PlannedFile, skipped map[string]bool) (PlannedFile, error) {
+	var marker spec.Marker
+	if err := json.Unmarshal(file.Content, &marker); err != nil {
+		return PlannedFile{}, fmt.Errorf("decode %s marker: %w", spec.MarkerFileName, err)
+	}
+	for rel := range skipped {
+		delete(marker.Files, rel)
+		delete(marker.Modes, rel)
+	}
+	if len(marker.Modes) == 0 {
+		marker.Modes = nil
+	}
+
+	content, err := json.MarshalIndent(marker, "", "  ")
+	if err != nil {
+		return PlannedFile{}, fmt.Errorf("encode %s marker: %w", spec.MarkerFileName, err)
+	}
+	file.Content = append(content, '\n')
+	return file, nil
+}
@@ -0,0 +1,89 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/ridzuwary/gokit-scaffold/internal/spec"
+)
+
+func TestInitAddsOnlyMissingFiles(t *testing.T) {
+	dir := t.TempDir()
+	existing := map[string]string{
+		"go.mod":    "module github.com/acme/billing\n\ngo 1.22\n",
+		"README.md": "# Billing\n",
+	}
+	for rel, content := range existing {
+		if err := os.WriteFile(filepath.Join(dir, rel), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+
+	module, goVersion, err := ReadGoMod(dir)
+	if err != nil {
+		t.Fatalf("read go.mod: %v", err)
+	}
+	project := spec.ProjectSpec{
+		Name:      NameFromModule(module),
+		Module:    module,
+		Dir:       dir,
+		HTTPPort:  8080,
+		GoVersion: goVersion,
+		Force:     true,
+	}
+	report, err := Init(project, Options{Version: "0.1.0"})
+	if err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	if got := report.Count(ActionSkipped); got != len(existing) {
+		t.Fatalf("skipped %d files, want %d: %+v", got, len(existing), report.Files)
+	}
+	for rel, want := range existing {
+		got, err := os.ReadFile(filepath.Join(dir, rel))
+		if err != nil {
+			t.Fatalf("read %s: %v", rel, err)
+		}
+		if string(got) != want {
+			t.Fatalf("%s was modified: %q", rel, got)
+		}
+	}
+	if _, err := os.Stat(filepath.Join(dir, "cmd", "server", "main.go")); err != nil {
+		t.Fatalf("expected missing outputs to be created: %v", err)
+	}
+
+	marker, err := spec.ReadMarker(filepath.Join(dir, spec.MarkerFileName))
+	if err != nil {
+		t.Fatalf("read marker: %v", err)
+	}
+	if marker.Spec.Name != "billing" || marker.Spec.Module != "github.com/acme/billing" || marker.Spec.GoVersion != "1.22" {
+		t.Fatalf("unexpected marker spec: %+v", marker.Spec)
+	}
+	for rel := range existing {
+		if _, ok := marker.Files[rel]; ok {
+			t.Fatalf("marker should not record pre-existing %s", rel)
+		}
+	}
+	if errs := spec.ValidateScaffoldDir(dir); len(errs) > 0 {
+		t.Fatalf("initialised directory is invalid: %v", errs)
+	}
+
+	if _, err := Init(project, Options{Version: "0.1.0"}); err == nil {
+		t.Fatalf("expected init to refuse a directory that already has a marker")
+	}
+}
+
+func TestNameFromModule(t *testing.T) {
+	tests := map[string]string{
+		"github.com/acme/billing":        "billing",
+		"github.com/acme/billing-svc/v2": "billing-svc",
+		"github.com/acme/BillingService": "billing-service",
+		"example.com/acme/billing_api":   "billing-api",
+	}
+	for module, want := range tests {
+		if got := NameFromModule(module); got != want {
+			t.Fatalf("NameFromModule(%q) = %q, want %q", module, got, want)
+		}
+	}
+}
@@ -1,67 +1,448 @@
 package generator
 
 import (
+	"errors"
 	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
 	"sort"
 	"strings"
+
+	"github.com/ridzuwary/gokit-scaffold/internal/spec"
+	"github.com/ridzuwary/gokit-scaffold/templates"
 )
 
-const ServiceHTTPTemplatePack = "service-http"
+const EmbeddedOrigin = "embedded"
 
 type ManifestEntry struct {
 	TemplatePath string
 	OutputPath   string
+	When         string
+	Mode         fs.FileMode
+	// Copy entries are emitted byte-for-byte instead of being rendered.
+	Copy bool
+	// Delims overrides the template action delimiters; empty means {{ }}.
+	Delims [2]string
+
+	// fsys is the layer the template is read from when the pack extends
+	// another; nil means the FS the entry is rendered with.
+	fsys fs.FS
+}
+
+func (e ManifestEntry) delims() (string, string) {
+	if e.Delims == [2]string{} {
+		return "{{", "}}"
+	}
+	return e.Delims[0], e.Delims[1]
+}
+
+func (e ManifestEntry) source(fsys fs.FS) fs.FS {
+	if e.fsys != nil {
+		return e.fsys
+	}
+	return fsys
+}
+
+type Pack struct {
+	Name        string
+	Description string
+	Version     string
+	Origin      string
+	FS          fs.FS
+	Entries     []ManifestEntry
+	Required    []string
+	Variables   []templates.PackVariable
+	Features    []templates.PackFeature
+	// Layers names the packs this one is built from, base first and ending
+	// with Name. It has one element unless the pack extends another.
+	Layers []string
+}
+
+func EmbeddedPack(templatePack string) (Pack, error) {
+	return embeddedPack(templatePack, nil)
+}
+
+func embeddedPack(templatePack string, chain []string) (Pack, error) {
+	chain, err := extendChain(chain, templatePack)
+	if err != nil {
+		return Pack{}, err
+	}
+	manifest, err := templates.EmbeddedManifest(templatePack)
+	if err != nil {
+		return Pack{}, err
+	}
+
+	var base *Pack
+	if manifest.Extends != "" {
+		if isPackPath(manifest.Extends) {
+			return Pack{}, fmt.Errorf("embedded template pack %s: can only extend embedded packs, not %s", templatePack, manifest.Extends)
+		}
+		extended, err := embeddedPack(manifest.Extends, chain)
+		if err != nil {
+			return Pack{}, fmt.Errorf("embedded template pack %s: extends: %w", templatePack, err)
+		}
+		base = &extended
+	}
+
+	pack, err := packFromManifest(templates.FS, templatePack, manifest, base)
+	if err != nil {
+		return Pack{}, fmt.Errorf("embedded template pack %s: %w", templatePack, err)
+	}
+	pack.Origin = EmbeddedOrigin
+	return pack, nil
+}
+
+// LoadPackDir loads a template pack from a local directory. The directory must
+// contain a pack.json whose entries map template files (relative to the
+// directory) to output paths; the pack is named after the directory.
+func LoadPackDir(dir string) (Pack, error) {
+	return loadPackDir(dir, nil)
+}
+
+func loadPackDir(dir string, chain []string) (Pack, error) {
+	abs, err := filepath.Abs(filepath.Clean(dir))
+	if err != nil {
+		return Pack{}, fmt.Errorf("resolve template directory: %w", err)
+	}
+	chain, err = extendChain(chain, abs)
+	if err != nil {
+		return Pack{}, err
+	}
+	info, err := os.Stat(abs)
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) {
+			return Pack{}, fmt.Errorf("template directory not found: %s", abs)
+		}
+		return Pack{}, fmt.Errorf("stat template directory: %w", err)
+	}
+	if !info.IsDir() {
+		return Pack{}, fmt.Errorf("template path is not a directory: %s", abs)
+	}
+
+	name := filepath.Base(abs)
+	if err := spec.ValidateTemplatePackName(name); err != nil {
+		return Pack{}, fmt.Errorf("template pack directory name %q %v", name, err)
+	}
+
+	fsys := os.DirFS(abs)
+	manifest, err := templates.ReadPackManifest(fsys, ".")
+	if err != nil {
+		return Pack{}, fmt.Errorf("load template pack %s: %w", abs, err)
+	}
+	if manifest.Name == "" {
+		manifest.Name = name
+	} else if manifest.Name != name {
+		return Pack{}, fmt.Errorf("load template pack %s: %s declares name %q but the directory is named %q", abs, templates.PackManifestFile, manifest.Name, name)
+	}
+
+	var base *Pack
+	if manifest.Extends != "" {
+		var extended Pack
+		if isPackPath(manifest.Extends) {
+			baseDir := filepath.FromSlash(manifest.Extends)
+			if !filepath.IsAbs(baseDir) {
+				baseDir = filepath.Join(abs, baseDir)
+			}
+			extended, err = loadPackDir(baseDir, chain)
+		} else {
+			extended, err = embeddedPack(manifest.Extends, chain)
+		}
+		if err != nil {
+			return Pack{}, fmt.Errorf("load template pack %s: extends: %w", abs, err)
+		}
+		base = &extended
+	}
+
+	pack, err := packFromManifest(fsys, ".", manifest, base)
+	if err != nil {
+		return Pack{}, fmt.Errorf("load template pack %s: %w", abs, err)
+	}
+	pack.Origin = abs
+	return pack, nil
+}
+
+// isPackPath reports whether an `extends` value is a pack directory rather
+// than the name of an embedded pack.
+func isPackPath(extends string) bool {
+	return strings.HasPrefix(extends, ".") || strings.HasPrefix(extends, "/") || filepath.IsAbs(extends)
+}
+
+// extendChain appends a pack to the chain of packs being loaded, failing when
+// a pack (transitively) extends itself.
+func extendChain(chain []string, pack string) ([]string, error) {
+	for _, loading := range chain {
+		if loading == pack {
+			return nil, fmt.Errorf("template pack %s extends itself: %s", pack, strings.Join(append(chain, pack), " -> "))
+		}
+	}
+	return append(append([]string(nil), chain...), pack), nil
+}
+
+// packFromManifest turns a decoded pack.json found at dir in fsys into a Pack,
+// resolving template paths against dir and checking the manifest is coherent.
+// When the manifest extends another pack, base is that pack and the manifest's
+// entries are layered over it: an entry adds an output or replaces the base
+// entry with the same output, and a delete entry removes one.
+func packFromManifest(fsys fs.FS, dir string, manifest templates.PackManifest, base *Pack) (Pack, error) {
+	pack := Pack{
+		Name:        manifest.Name,
+		Description: manifest.Description,
+		Version:     manifest.Version,
+		FS:          fsys,
+		Entries:     make([]ManifestEntry, 0, len(manifest.Entries)),
+		Required:    append([]string(nil), manifest.Required...),
+		Variables:   append([]templates.PackVariable(nil), manifest.Variables...),
+		Features:    append([]templates.PackFeature(nil), manifest.Features...),
+		Layers:      []string{manifest.Name},
+	}
+	deleted := map[string]bool{}
+	for _, entry := range manifest.Entries {
+		if entry.Delete {
+			if base == nil {
+				return Pack{}, fmt.Errorf("entry %s: delete only applies to a pack that extends another", entry.Output)
+			}
+			if entry.Template != "" || entry.When != "" || entry.Mode != "" || entry.Copy || entry.Delims != nil {
+				return Pack{}, fmt.Errorf("entry %s: a delete entry takes only an output", entry.Output)
+			}
+			deleted[entry.Output] = true
+			continue
+		}
+
+		templatePath := entry.Template
+		if fs.ValidPath(templatePath) {
+			templatePath = path.Join(dir, templatePath)
+		}
+		mode := spec.DefaultFileMode
+		if entry.Mode != "" {
+			parsed, err := spec.ParseFileMode(entry.Mode)
+			if err != nil {
+				return Pack{}, fmt.Errorf("entry %s: mode %v", entry.Output, err)
+			}
+			mode = parsed
+		}
+		var delims [2]string
+		if entry.Delims != nil {
+			if entry.Copy {
+				return Pack{}, fmt.Errorf("entry %s: delims have no effect on a copy entry", entry.Output)
+			}
+			if len(entry.Delims) != 2 || strings.TrimSpace(entry.Delims[0]) == "" || strings.TrimSpace(entry.Delims[1]) == "" {
+				return Pack{}, fmt.Errorf("entry %s: delims must be a left and a right delimiter, such as [\"[[\", \"]]\"]", entry.Output)
+			}
+			delims = [2]string{entry.Delims[0], entry.Delims[1]}
+		}
+		pack.Entries = append(pack.Entries, ManifestEntry{
+			TemplatePath: templatePath,
+			OutputPath:   entry.Output,
+			When:         entry.When,
+			Mode:         mode,
+			Copy:         entry.Copy,
+			Delims:       delims,
+		})
+	}
+	if base != nil {
+		if err := layerPack(&pack, *base, deleted); err != nil {
+			return Pack{}, err
+		}
+	}
+	if err := validatePack(pack); err != nil {
+		return Pack{}, err
+	}
+	sortEntries(pack.Entries)
+	sort.Strings(pack.Required)
+
+	return pack, nil
+}
+
+// layerPack merges base under pack. Base entries keep reading from the base
+// pack's files; variables and features declared again by pack replace the
+// base declarations of the same name.
+func layerPack(pack *Pack, base Pack, deleted map[string]bool) error {
+	overridden := make(map[string]bool, len(pack.Entries))
+	for i := range pack.Entries {
+		if deleted[pack.Entries[i].OutputPath] {
+			return fmt.Errorf("entry %s is both deleted and defined", pack.Entries[i].OutputPath)
+		}
+		overridden[pack.Entries[i].OutputPath] = true
+		pack.Entries[i].fsys = pack.FS
+	}
+
+	inBase := make(map[string]bool, len(base.Entries))
+	entries := make([]ManifestEntry, 0, len(base.Entries)+len(pack.Entries))
+	for _, entry := range base.Entries {
+		inBase[entry.OutputPath] = true
+		if overridden[entry.OutputPath] || deleted[entry.OutputPath] {
+			continue
+		}
+		entry.fsys = entry.source(base.FS)
+		entries = append(entries, entry)
+	}
+	unmatched := make([]string, 0, len(deleted))
+	for output := range deleted {
+		if !inBase[output] {
+			unmatched = append(unmatched, output)
+		}
+	}
+	if len(unmatched) > 0 {
+		sort.Strings(unmatched)
+		return fmt.Errorf("entry %s: delete matches no entry of %s", unmatched[0], base.Name)
+	}
+	pack.Entries = append(entries, pack.Entries...)
+
+	required := make([]string, 0, len(base.Required)+len(pack.Required))
+	listed := map[string]bool{}
+	for _, rel := range append(append([]string(nil), base.Required...), pack.Required...) {
+		if deleted[rel] || listed[rel] {
+			continue
+		}
+		listed[rel] = true
+		required = append(required, rel)
+	}
+	pack.Required = required
+
+	variables := make([]templates.PackVariable, 0, len(base.Variables)+len(pack.Variables))
+	redeclared := map[string]bool{}
+	for _, variable := range pack.Variables {
+		redeclared[variable.Name] = true
+	}
+	for _, variable := range base.Variables {
+		if !redeclared[variable.Name] {
+			variables = append(variables, variable)
+		}
+	}
+	pack.Variables = append(variables, pack.Variables...)
+
+	features := make([]templates.PackFeature, 0, len(base.Features)+len(pack.Features))
+	redeclared = map[string]bool{}
+	for _, feature := range pack.Features {
+		redeclared[feature.Name] = true
+	}
+	for _, feature := range base.Features {
+		if !redeclared[feature.Name] {
+			features = append(features, feature)
+		}
+	}
+	pack.Features = append(features, pack.Features...)
+
+	pack.Layers = append(append([]string(nil), base.Layers...), pack.Name)
+	return nil
 }
 
-var serviceHTTPManifest = []ManifestEntry{
-	{TemplatePath: "service-http/.gokit-scaffold.tmpl", OutputPath: ".gokit-scaffold"},
-	{TemplatePath: "service-http/README.md.tmpl", OutputPath: "README.md"},
-	{TemplatePath: "service-http/cmd/server/main.go.tmpl", OutputPath: "cmd/server/main.go"},
-	{TemplatePath: "service-http/go.mod.tmpl", OutputPath: "go.mod"},
-	{TemplatePath: "service-http/internal/config/config.go.tmpl", OutputPath: "internal/config/config.go"},
-	{TemplatePath: "service-http/internal/httpserver/health.go.tmpl", OutputPath: "internal/httpserver/health.go"},
-	{TemplatePath: "service-http/internal/httpserver/server.go.tmpl", OutputPath: "internal/httpserver/server.go"},
-	{TemplatePath: "service-http/internal/logging/logging.go.tmpl", OutputPath: "internal/logging/logging.go"},
+func validatePack(pack Pack) error {
+	if len(pack.Entries) == 0 {
+		return errors.New("manifest has no entries")
+	}
+
+	features := make(map[string]bool, len(pack.Features))
+	for _, feature := range pack.Features {
+		if err := spec.ValidateFeatureName(feature.Name); err != nil {
+			return fmt.Errorf("feature %q %v", feature.Name, err)
+		}
+		if features[feature.Name] {
+			return fmt.Errorf("duplicate feature %s", feature.Name)
+		}
+		features[feature.Name] = true
+	}
+
+	seen := make(map[string]bool, len(pack.Entries))
+	templated := make(map[string]bool, len(pack.Entries))
+	for _, entry := range pack.Entries {
+		if entry.When != "" {
+			feature, _, err := parseCondition(entry.When)
+			if err != nil {
+				return fmt.Errorf("entry %s: %w", entry.OutputPath, err)
+			}
+			if !features[feature] {
+				return fmt.Errorf("entry %s: condition refers to undeclared feature %q", entry.OutputPath, feature)
+			}
+		}
+
+		if !fs.ValidPath(entry.TemplatePath) || entry.TemplatePath == "." {
+			return fmt.Errorf("invalid template path %q", entry.TemplatePath)
+		}
+		if info, err := fs.Stat(entry.source(pack.FS), entry.TemplatePath); err != nil {
+			return fmt.Errorf("template %s: %w", entry.TemplatePath, err)
+		} else if info.IsDir() {
+			return fmt.Errorf("template %s is a directory", entry.TemplatePath)
+		}
+
+		if !fs.ValidPath(entry.OutputPath) || entry.OutputPath == "." {
+			return fmt.Errorf("invalid output path %q for template %s (must be relative, slash-separated, without ..)", entry.OutputPath, entry.TemplatePath)
+		}
+		if entry.OutputPath == spec.MarkerFileName {
+			return fmt.Errorf("output path %s is reserved for the scaffold marker", spec.MarkerFileName)
+		}
+		if seen[entry.OutputPath] {
+			return fmt.Errorf("duplicate output path %s", entry.OutputPath)
+		}
+		seen[entry.OutputPath] = true
+		if left, _ := entry.delims(); strings.Contains(entry.OutputPath, left) {
+			templated[entry.OutputPath] = true
+		}
+	}
+
+	for _, rel := range pack.Required {
+		if templated[rel] {
+			return fmt.Errorf("required file %s: templated output paths cannot be required", rel)
+		}
+		if rel != spec.MarkerFileName && !seen[rel] {
+			return fmt.Errorf("required file %s is not produced by any entry", rel)
+		}
+	}
+
+	names := make(map[string]bool, len(pack.Variables))
+	for _, variable := range pack.Variables {
+		if err := spec.ValidateVariableName(variable.Name); err != nil {
+			return fmt.Errorf("variable %q %v", variable.Name, err)
+		}
+		if names[variable.Name] {
+			return fmt.Errorf("duplicate variable %s", variable.Name)
+		}
+		names[variable.Name] = true
+	}
+
+	return nil
 }
 
 func TemplatePacks() []string {
-	return []string{ServiceHTTPTemplatePack}
+	return templates.EmbeddedPacks()
 }
 
 func Manifest(templatePack string) ([]ManifestEntry, error) {
-	var manifest []ManifestEntry
-	switch templatePack {
-	case ServiceHTTPTemplatePack:
-		manifest = append([]ManifestEntry(nil), serviceHTTPManifest...)
-	default:
-		return nil, fmt.Errorf("unknown template pack: %s", templatePack)
+	pack, err := EmbeddedPack(templatePack)
+	if err != nil {
+		return nil, err
 	}
+	return pack.Entries, nil
+}
 
-	sort.Slice(manifest, func(i, j int) bool {
-		if manifest[i].OutputPath == manifest[j].OutputPath {
-			return manifest[i].TemplatePath < manifest[j].TemplatePath
+func sortEntries(entries []ManifestEntry) {
+	sort.Slice(entries, func(i, j int) bool {
+		if entries[i].OutputPath == entries[j].OutputPath {
+			return entries[i].TemplatePath < entries[j].TemplatePath
 		}
-		return manifest[i].OutputPath < manifest[j].OutputPath
+		return entries[i].OutputPath < entries[j].OutputPath
 	})
-
-	return manifest, nil
 }
 
-func OutputPaths(templatePack string) ([]string, error) {
-	manifest, err := Manifest(templatePack)
+// OutputPaths lists what pack generates for s, with templated output paths
+// resolved.
+func OutputPaths(pack Pack, s spec.ProjectSpec) ([]string, error) {
+	plan, err := planPack(pack, s, "")
 	if err != nil {
 		return nil, err
 	}
 
-	paths := make([]string, 0, len(manifest))
-	for _, entry := range manifest {
-		paths = append(paths, entry.OutputPath)
+	paths := make([]string, 0, len(plan.Files))
+	for _, file := range plan.Files {
+		paths = append(paths, file.OutputPath)
 	}
 	return paths, nil
 }
 
-func OutputTree(templatePack string) (string, error) {
-	paths, err := OutputPaths(templatePack)
+func OutputTree(pack Pack, s spec.ProjectSpec) (string, error) {
+	paths, err := OutputPaths(pack, s)
 	if err != nil {
 		return "", err
 	}
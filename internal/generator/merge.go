@@ -0,0 +1,150 @@
+package generator
+
+import (
+	"bytes"
+	"fmt"
+)
+
+type MergeLabels struct {
+	Ours   string
+	Base   string
+	Theirs string
+}
+
+// Merge3 performs a line-based three-way merge of ours and theirs against their
+// common ancestor base. Hunks changed on both sides in different ways are
+// written with diff3-style conflict markers and reported via the bool result.
+func Merge3(base, ours, theirs []byte, labels MergeLabels) ([]byte, bool) {
+	b := splitLines(base)
+	o := splitLines(ours)
+	t := splitLines(theirs)
+
+	matchO := lcsMatch(b, o)
+	matchT := lcsMatch(b, t)
+
+	var out bytes.Buffer
+	conflict := false
+	i, io, it := 0, 0, 0
+	for {
+		if i < len(b) && matchO[i] == io && matchT[i] == it {
+			out.Write(b[i])
+			i, io, it = i+1, io+1, it+1
+			continue
+		}
+
+		k := i
+		for k < len(b) && (matchO[k] < 0 || matchT[k] < 0) {
+			k++
+		}
+		eo, et := len(o), len(t)
+		if k < len(b) {
+			eo, et = matchO[k], matchT[k]
+		}
+		if k == i && eo == io && et == it {
+			break
+		}
+
+		baseHunk, oursHunk, theirsHunk := b[i:k], o[io:eo], t[it:et]
+		switch {
+		case linesEqual(oursHunk, baseHunk):
+			writeLines(&out, theirsHunk)
+		case linesEqual(theirsHunk, baseHunk), linesEqual(oursHunk, theirsHunk):
+			writeLines(&out, oursHunk)
+		default:
+			conflict = true
+			writeConflict(&out, baseHunk, oursHunk, theirsHunk, labels)
+		}
+
+		i, io, it = k, eo, et
+	}
+
+	return out.Bytes(), conflict
+}
+
+func writeConflict(out *bytes.Buffer, base, ours, theirs [][]byte, labels MergeLabels) {
+	fmt.Fprintf(out, "<<<<<<< %s\n", labels.Ours)
+	writeLines(out, ours)
+	ensureNewline(out)
+	fmt.Fprintf(out, "||||||| %s\n", labels.Base)
+	writeLines(out, base)
+	ensureNewline(out)
+	out.WriteString("=======\n")
+	writeLines(out, theirs)
+	ensureNewline(out)
+	fmt.Fprintf(out, ">>>>>>> %s\n", labels.Theirs)
+}
+
+func ensureNewline(out *bytes.Buffer) {
+	if out.Len() > 0 && out.Bytes()[out.Len()-1] != '\n' {
+		out.WriteByte('\n')
+	}
+}
+
+func writeLines(out *bytes.Buffer, lines [][]byte) {
+	for _, line := range lines {
+		out.Write(line)
+	}
+}
+
+func splitLines(content []byte) [][]byte {
+	var lines [][]byte
+	for len(content) > 0 {
+		n := bytes.IndexByte(content, '\n')
+		if n < 0 {
+			lines = append(lines, content)
+			break
+		}
+		lines = append(lines, content[:n+1])
+		content = content[n+1:]
+	}
+	return lines
+}
+
+func linesEqual(a, b [][]byte) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if !bytes.Equal(a[i], b[i]) {
+			return false
+		}
+	}
+	return true
+}
+
+// lcsMatch returns, for every line of a, the index of the line of b it is
+// paired with in a longest common subsequence, or -1 when it is unmatched.
+func lcsMatch(a, b [][]byte) []int {
+	table := make([][]int, len(a)+1)
+	for i := range table {
+		table[i] = make([]int, len(b)+1)
+	}
+	for i := len(a) - 1; i >= 0; i-- {
+		for j := len(b) - 1; j >= 0; j-- {
+			if bytes.Equal(a[i], b[j]) {
+				table[i][j] = table[i+1][j+1] + 1
+			} else {
+				table[i][j] = max(table[i+1][j], table[i][j+1])
+			}
+		}
+	}
+
+	match := make([]int, len(a))
+	for i := range match {
+		match[i] = -1
+	}
+	i, j := 0, 0
+	for i < len(a) && j < len(b) {
+		switch {
+		case bytes.Equal(a[i], b[j]):
+			match[i] = j
+			i++
+			j++
+		case table[i+1][j] >= table[i][j+1]:
+			i++
+		default:
+			j++
+		}
+	}
+	return match
+}
@@ -0,0 +1,78 @@
+package generator
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ridzuwary/gokit-scaffold/templates"
+)
+
+// LoadPack finds a template pack by reference. A path (starting with "." or
+// "/", or containing a separator) is loaded as a pack directory; a name is an
+// embedded pack, or else a pack directory of that name under searchDir.
+func LoadPack(ref, searchDir string) (Pack, error) {
+	if isPackPath(ref) || strings.ContainsAny(ref, `/\`) {
+		return LoadPackDir(ref)
+	}
+	if slices.Contains(templates.EmbeddedPacks(), ref) {
+		return EmbeddedPack(ref)
+	}
+	if searchDir != "" {
+		dir := filepath.Join(searchDir, ref)
+		if _, err := os.Stat(filepath.Join(dir, templates.PackManifestFile)); err == nil {
+			return LoadPackDir(dir)
+		}
+	}
+	return Pack{}, fmt.Errorf("unknown template pack: %s (available: %s)", ref, strings.Join(templates.EmbeddedPacks(), ", "))
+}
+
+// ListPacks returns the embedded packs followed by the packs in the
+// subdirectories of searchDir that contain a pack.json, each group sorted by
+// name. A broken pack fails the whole listing.
+func ListPacks(searchDir string) ([]Pack, error) {
+	var packs []Pack
+	for _, name := range templates.EmbeddedPacks() {
+		pack, err := EmbeddedPack(name)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+	if searchDir == "" {
+		return packs, nil
+	}
+
+	entries, err := os.ReadDir(searchDir)
+	if err != nil {
+		return nil, fmt.Errorf("read template pack directory: %w", err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		_, err := os.Stat(filepath.Join(searchDir, entry.Name(), templates.PackManifestFile))
+		if errors.Is(err, fs.ErrNotExist) {
+			continue
+		} else if err != nil {
+			return nil, fmt.Errorf("stat template pack %s: %w", entry.Name(), err)
+		}
+		names = append(names, entry.Name())
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		pack, err := LoadPackDir(filepath.Join(searchDir, name))
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack)
+	}
+	return packs, nil
+}
@@ -0,0 +1,379 @@
+package generator
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"go/ast"
+	"go/build"
+	"go/parser"
+	"go/scanner"
+	"go/token"
+	"go/types"
+	"go/version"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ridzuwary/gokit-scaffold/internal/spec"
+)
+
+type TypeError struct {
+	OutputPath   string
+	TemplatePath string
+	Line         int
+	Column       int
+	Msg          string
+}
+
+func (e TypeError) String() string {
+	s := fmt.Sprintf("%s:%d:%d: %s", e.OutputPath, e.Line, e.Column, e.Msg)
+	if e.TemplatePath != "" {
+		s += " (from template " + e.TemplatePath + ")"
+	}
+	return s
+}
+
+type TypeCheckError struct {
+	Errors []TypeError
+}
+
+func (e *TypeCheckError) Error() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "generated code does not type-check (%d errors):", len(e.Errors))
+	for _, typeErr := range e.Errors {
+		b.WriteString("\n  ")
+		b.WriteString(typeErr.String())
+	}
+	return b.String()
+}
+
+// TypeCheck parses and type-checks every non-test Go package among files
+// without the go command or network access. Packages of the module declared in
+// files' go.mod are resolved from files, leaving out those excluded by their
+// name or build constraints; everything else must be in GOROOT.
+// Problems in the files are returned as TypeErrors attributed to the template
+// that produced each file.
+func TypeCheck(files []PlannedFile) ([]TypeError, error) {
+	var goMod []byte
+	for _, file := range files {
+		if file.OutputPath == "go.mod" {
+			goMod = file.Content
+		}
+	}
+	if goMod == nil {
+		return nil, errors.New("type-check: no go.mod among the files")
+	}
+	module, goVersion := parseGoMod(goMod)
+	if module == "" {
+		return nil, errors.New("type-check: go.mod has no module directive")
+	}
+
+	imp := newSourceImporter(module, goVersion)
+	templates := make(map[string]string, len(files))
+	for _, file := range files {
+		if !strings.HasSuffix(file.OutputPath, ".go") || strings.HasSuffix(file.OutputPath, "_test.go") {
+			continue
+		}
+		if !imp.matchFile(file) {
+			continue
+		}
+		templates[file.OutputPath] = file.TemplatePath
+
+		parsed, err := parser.ParseFile(imp.fset, file.OutputPath, file.Content, parser.SkipObjectResolution)
+		if err != nil {
+			var list scanner.ErrorList
+			if !errors.As(err, &list) {
+				return nil, fmt.Errorf("parse %s: %w", file.OutputPath, err)
+			}
+			for _, parseErr := range list {
+				imp.report(parseErr.Pos, parseErr.Msg)
+			}
+			continue
+		}
+		importPath := module
+		if dir := path.Dir(file.OutputPath); dir != "." {
+			importPath = module + "/" + dir
+		}
+		imp.local[importPath] = append(imp.local[importPath], parsed)
+	}
+
+	importPaths := make([]string, 0, len(imp.local))
+	for importPath := range imp.local {
+		importPaths = append(importPaths, importPath)
+	}
+	sort.Strings(importPaths)
+	for _, importPath := range importPaths {
+		if _, err := imp.ImportFrom(importPath, "", 0); err != nil && len(imp.errs) == 0 {
+			return nil, err
+		}
+	}
+
+	for i := range imp.errs {
+		imp.errs[i].TemplatePath = templates[imp.errs[i].OutputPath]
+	}
+	sort.SliceStable(imp.errs, func(i, j int) bool {
+		a, b := imp.errs[i], imp.errs[j]
+		if a.OutputPath != b.OutputPath {
+			return a.OutputPath < b.OutputPath
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return imp.errs, nil
+}
+
+// Verify type-checks a render plan and returns a *TypeCheckError listing the
+// problems, if any.
+func Verify(plan RenderPlan) error {
+	typeErrs, err := TypeCheck(plan.Files)
+	if err != nil {
+		return err
+	}
+	if len(typeErrs) > 0 {
+		return &TypeCheckError{Errors: typeErrs}
+	}
+	return nil
+}
+
+// TypeCheckDir type-checks the scaffold in dir as TypeCheck does, reading the Go
+// files from disk. Generated files are attributed to their templates when the
+// pack is embedded or its recorded directory can still be loaded.
+func TypeCheckDir(dir string) ([]TypeError, error) {
+	marker, err := spec.ReadMarker(filepath.Join(dir, spec.MarkerFileName))
+	if err != nil {
+		return nil, err
+	}
+	templates := map[string]string{}
+	if pack, err := markerPack(marker); err == nil {
+		templates = outputTemplates(pack, marker)
+	}
+
+	var files []PlannedFile
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		rel, err := filepath.Rel(dir, p)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+		if d.IsDir() {
+			name := d.Name()
+			if rel != "." && (strings.HasPrefix(name, ".") || strings.HasPrefix(name, "_") || name == "testdata" || name == "vendor") {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if rel != "go.mod" && !strings.HasSuffix(rel, ".go") {
+			return nil
+		}
+		content, err := os.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		files = append(files, PlannedFile{TemplatePath: templates[rel], OutputPath: rel, Content: content})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read scaffold files: %w", err)
+	}
+
+	return TypeCheck(files)
+}
+
+// markerPack loads the pack a marker was generated from.
+func markerPack(marker spec.Marker) (Pack, error) {
+	if marker.TemplateDir != "" {
+		return LoadPackDir(marker.TemplateDir)
+	}
+	return EmbeddedPack(marker.TemplatePack)
+}
+
+// outputTemplates maps the output paths pack renders for the marker's spec to
+// the templates that produce them. Entries whose path does not render map to
+// nothing.
+func outputTemplates(pack Pack, marker spec.Marker) map[string]string {
+	data := newTemplateData(spec.ProjectSpec{
+		Name:      marker.Spec.Name,
+		Module:    marker.Spec.Module,
+		HTTPPort:  marker.Spec.HTTPPort,
+		GoVersion: marker.Spec.GoVersion,
+	}, marker.Version)
+	if _, typed, err := resolveVariables(pack.Variables, marker.Spec.Vars); err == nil {
+		data.Vars = typed
+	}
+	if features, err := resolveFeatures(pack.Features, ExactFeatures(pack, marker.Spec.Features)); err == nil {
+		data.Features = features
+	}
+
+	templates := make(map[string]string, len(pack.Entries))
+	for _, entry := range pack.Entries {
+		if outputPath, err := renderOutputPath(entry, data); err == nil {
+			templates[outputPath] = entry.TemplatePath
+		}
+	}
+	return templates
+}
+
+// parseGoMod extracts the module path and go version from a go.mod file. It
+// only understands the two directives it needs.
+func parseGoMod(content []byte) (module, goVersion string) {
+	lines := bufio.NewScanner(bytes.NewReader(content))
+	for lines.Scan() {
+		line := lines.Text()
+		if i := strings.Index(line, "//"); i >= 0 {
+			line = line[:i]
+		}
+		fields := strings.Fields(line)
+		if len(fields) != 2 {
+			continue
+		}
+		switch fields[0] {
+		case "module":
+			module = fields[1]
+			if unquoted, err := strconv.Unquote(module); err == nil {
+				module = unquoted
+			}
+		case "go":
+			goVersion = fields[1]
+		}
+	}
+	return module, goVersion
+}
+
+// sourceImporter type-checks imported packages from source: module packages
+// from the parsed files, standard library packages from GOROOT with cgo off so
+// that nothing needs a C toolchain.
+type sourceImporter struct {
+	fset      *token.FileSet
+	ctx       build.Context
+	module    string
+	goVersion string
+	local     map[string][]*ast.File
+	packages  map[string]*types.Package
+	checking  map[string]bool
+	errs      []TypeError
+}
+
+func newSourceImporter(module, goVersion string) *sourceImporter {
+	ctx := build.Default
+	ctx.CgoEnabled = false
+	goVersion = "go" + goVersion
+	if !version.IsValid(goVersion) {
+		goVersion = ""
+	}
+	return &sourceImporter{
+		fset:      token.NewFileSet(),
+		ctx:       ctx,
+		module:    module,
+		goVersion: goVersion,
+		local:     map[string][]*ast.File{},
+		packages:  map[string]*types.Package{"unsafe": types.Unsafe},
+		checking:  map[string]bool{},
+	}
+}
+
+// matchFile reports whether file is built for the importer's context, as the
+// go command decides from its name and build constraints. Files whose header
+// cannot be read are kept so that the parser reports the problem.
+func (imp *sourceImporter) matchFile(file PlannedFile) bool {
+	ctx := imp.ctx
+	ctx.OpenFile = func(string) (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(file.Content)), nil
+	}
+	dir, name := path.Split(file.OutputPath)
+	match, err := ctx.MatchFile(dir, name)
+	return match || err != nil
+}
+
+func (imp *sourceImporter) report(pos token.Position, msg string) {
+	imp.errs = append(imp.errs, TypeError{
+		OutputPath: pos.Filename,
+		Line:       pos.Line,
+		Column:     pos.Column,
+		Msg:        msg,
+	})
+}
+
+func (imp *sourceImporter) Import(importPath string) (*types.Package, error) {
+	return imp.ImportFrom(importPath, "", 0)
+}
+
+func (imp *sourceImporter) ImportFrom(importPath, srcDir string, _ types.ImportMode) (*types.Package, error) {
+	if pkg, ok := imp.packages[importPath]; ok {
+		return pkg, nil
+	}
+	if imp.checking[importPath] {
+		return nil, fmt.Errorf("import cycle through %s", importPath)
+	}
+	imp.checking[importPath] = true
+	defer delete(imp.checking, importPath)
+
+	if files, ok := imp.local[importPath]; ok {
+		conf := types.Config{
+			Importer:  imp,
+			GoVersion: imp.goVersion,
+			Error: func(err error) {
+				var typeErr types.Error
+				if errors.As(err, &typeErr) {
+					imp.report(typeErr.Fset.Position(typeErr.Pos), typeErr.Msg)
+				}
+			},
+		}
+		pkg, _ := conf.Check(importPath, imp.fset, files, nil)
+		imp.packages[importPath] = pkg
+		return pkg, nil
+	}
+	if importPath == imp.module || strings.HasPrefix(importPath, imp.module+"/") {
+		return nil, fmt.Errorf("unresolved import %s: no such package in the module", importPath)
+	}
+
+	dir, err := imp.goroot(importPath, srcDir)
+	if err != nil {
+		return nil, err
+	}
+	bp, err := imp.ctx.ImportDir(dir, 0)
+	if err != nil {
+		return nil, fmt.Errorf("unresolved import %s: %w", importPath, err)
+	}
+	files := make([]*ast.File, 0, len(bp.GoFiles))
+	for _, name := range bp.GoFiles {
+		parsed, err := parser.ParseFile(imp.fset, filepath.Join(dir, name), nil, parser.SkipObjectResolution)
+		if err != nil {
+			return nil, fmt.Errorf("parse %s: %w", importPath, err)
+		}
+		files = append(files, parsed)
+	}
+	conf := types.Config{Importer: imp, IgnoreFuncBodies: true}
+	pkg, err := conf.Check(importPath, imp.fset, files, nil)
+	if err != nil {
+		return nil, fmt.Errorf("type-check %s: %w", importPath, err)
+	}
+	imp.packages[importPath] = pkg
+	return pkg, nil
+}
+
+// goroot locates a standard library package. Paths with a dot in their first
+// element are only found in GOROOT's vendor directory, and only when imported
+// from the standard library itself; anything else would need the module cache.
+func (imp *sourceImporter) goroot(importPath, srcDir string) (string, error) {
+	src := filepath.Join(imp.ctx.GOROOT, "src")
+	first, _, _ := strings.Cut(importPath, "/")
+	if !strings.Contains(first, ".") {
+		return filepath.Join(src, filepath.FromSlash(importPath)), nil
+	}
+	if rel, err := filepath.Rel(src, srcDir); err == nil && srcDir != "" && !strings.HasPrefix(rel, "..") {
+		return filepath.Join(src, "vendor", filepath.FromSlash(importPath)), nil
+	}
+	return "", fmt.Errorf("unresolved import %s: only standard library and module packages can be checked offline", importPath)
+}
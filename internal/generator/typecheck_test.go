@@ -0,0 +1,149 @@
+package generator
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/ridzuwary/gokit-scaffold/internal/spec"
+)
+
+func TestTypeCheck(t *testing.T) {
+	goMod := PlannedFile{TemplatePath: "pack/go.mod.tmpl", OutputPath: "go.mod", Content: []byte("module example.com/app\n\ngo 1.22.0\n")}
+	lib := PlannedFile{TemplatePath: "pack/lib.go.tmpl", OutputPath: "internal/lib/lib.go", Content: []byte("package lib\n\nfunc Answer() int { return 42 }\n")}
+
+	tests := []struct {
+		name string
+		main string
+		want []string
+	}{
+		{
+			name: "valid",
+			main: "package main\n\nimport (\n\t\"fmt\"\n\n\t\"example.com/app/internal/lib\"\n)\n\nfunc main() { fmt.Println(lib.Answer()) }\n",
+		},
+		{
+			name: "unresolved import",
+			main: "package main\n\nimport \"github.com/acme/missing\"\n\nfunc main() { missing.Run() }\n",
+			want: []string{"cmd/app/main.go:3:8:", "github.com/acme/missing", "(from template pack/main.go.tmpl)"},
+		},
+		{
+			name: "missing module package",
+			main: "package main\n\nimport \"example.com/app/internal/nope\"\n\nfunc main() { nope.Run() }\n",
+			want: []string{"cmd/app/main.go:3:8:", "example.com/app/internal/nope"},
+		},
+		{
+			name: "unused variable",
+			main: "package main\n\nfunc main() {\n\tx := 1\n}\n",
+			want: []string{"cmd/app/main.go:4:2: declared and not used: x"},
+		},
+		{
+			name: "type error",
+			main: "package main\n\nimport \"example.com/app/internal/lib\"\n\nfunc main() {\n\tvar s string = lib.Answer()\n\t_ = s\n}\n",
+			want: []string{"cmd/app/main.go:6:17:", "cannot use lib.Answer()"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			main := PlannedFile{TemplatePath: "pack/main.go.tmpl", OutputPath: "cmd/app/main.go", Content: []byte(tt.main)}
+			typeErrs, err := TypeCheck([]PlannedFile{goMod, lib, main})
+			if err != nil {
+				t.Fatalf("type-check: %v", err)
+			}
+			if len(tt.want) == 0 {
+				if len(typeErrs) > 0 {
+					t.Fatalf("unexpected errors: %v", typeErrs)
+				}
+				return
+			}
+			if len(typeErrs) != 1 {
+				t.Fatalf("got %d errors, want 1: %v", len(typeErrs), typeErrs)
+			}
+			for _, want := range tt.want {
+				if !strings.Contains(typeErrs[0].String(), want) {
+					t.Fatalf("error %q does not mention %q", typeErrs[0], want)
+				}
+			}
+		})
+	}
+}
+
+func TestTypeCheckBuildConstraints(t *testing.T) {
+	files := []PlannedFile{
+		{OutputPath: "go.mod", Content: []byte("module example.com/app\n\ngo 1.22.0\n")},
+		{OutputPath: "sep_windows.go", Content: []byte("package app\n\nconst Sep = '\\\\'\n")},
+		{OutputPath: "sep_other.go", Content: []byte("//go:build !windows\n\npackage app\n\nconst Sep = '/'\n")},
+		{OutputPath: "gen.go", Content: []byte("//go:build ignore\n\npackage main\n\nfunc main() {}\n")},
+		{OutputPath: "app.go", Content: []byte("package app\n\nfunc Root() string { return string(Sep) }\n")},
+	}
+
+	typeErrs, err := TypeCheck(files)
+	if err != nil {
+		t.Fatalf("type-check: %v", err)
+	}
+	if len(typeErrs) > 0 {
+		t.Fatalf("files excluded by build constraints were checked: %v", typeErrs)
+	}
+}
+
+func TestTypeCheckDirTemplateDirPack(t *testing.T) {
+	packDir := filepath.Join(t.TempDir(), "broken")
+	files := map[string]string{
+		"pack.json":    `{"entries": [{"template": "go.mod.tmpl", "output": "go.mod"}, {"template": "main.go.tmpl", "output": "cmd/{{ .Name }}/main.go"}]}`,
+		"go.mod.tmpl":  "module {{ .Module }}\n\ngo 1.22.0\n",
+		"main.go.tmpl": "package main\n\nfunc main() {\n\tvar port string = {{ .HTTPPort }}\n\t_ = port\n}\n",
+	}
+	if err := os.MkdirAll(packDir, 0o755); err != nil {
+		t.Fatalf("mkdir %s: %v", packDir, err)
+	}
+	for rel, content := range files {
+		if err := os.WriteFile(filepath.Join(packDir, rel), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+
+	dir := filepath.Join(t.TempDir(), "hello-api")
+	project := spec.ProjectSpec{
+		Name:        "hello-api",
+		Module:      "github.com/example/hello-api",
+		Dir:         dir,
+		HTTPPort:    8080,
+		TemplateDir: packDir,
+	}
+	if _, err := Generate(project, Options{Version: "0.1.0"}); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+
+	typeErrs, err := TypeCheckDir(dir)
+	if err != nil {
+		t.Fatalf("type-check: %v", err)
+	}
+	if len(typeErrs) != 1 || typeErrs[0].OutputPath != "cmd/hello-api/main.go" || typeErrs[0].TemplatePath != "main.go.tmpl" {
+		t.Fatalf("expected one error attributed to main.go.tmpl, got %v", typeErrs)
+	}
+}
+
+func TestOutputTemplatesRendersPaths(t *testing.T) {
+	pack := Pack{Entries: []ManifestEntry{
+		{TemplatePath: "main.go.tmpl", OutputPath: "cmd/{{ .Name }}/main.go"},
+		{TemplatePath: "config.go.tmpl", OutputPath: "internal/[[ snake .Name ]]/config.go", Delims: [2]string{"[[", "]]"}},
+		{TemplatePath: "go.mod.tmpl", OutputPath: "go.mod"},
+	}}
+	marker := spec.Marker{Spec: spec.MarkerSpec{Name: "billing-api", Module: "github.com/acme/billing-api"}}
+
+	got := outputTemplates(pack, marker)
+	want := map[string]string{
+		"cmd/billing-api/main.go":        "main.go.tmpl",
+		"internal/billing_api/config.go": "config.go.tmpl",
+		"go.mod":                         "go.mod.tmpl",
+	}
+	if len(got) != len(want) {
+		t.Fatalf("got %v, want %v", got, want)
+	}
+	for outputPath, templatePath := range want {
+		if got[outputPath] != templatePath {
+			t.Fatalf("%s: template %q, want %q (all: %v)", outputPath, got[outputPath], templatePath, got)
+		}
+	}
+}
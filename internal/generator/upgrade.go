@@ -0,0 +1,327 @@
+package generator
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/ridzuwary/gokit-scaffold/internal/spec"
+	"github.com/ridzuwary/gokit-scaffold/templates"
+)
+
+type UpgradeStatus string
+
+const (
+	UpgradeUnchanged    UpgradeStatus = "unchanged"
+	UpgradeUpdated      UpgradeStatus = "updated"
+	UpgradeMerged       UpgradeStatus = "merged"
+	UpgradeConflict     UpgradeStatus = "conflict"
+	UpgradeAdded        UpgradeStatus = "added"
+	UpgradeDeleted      UpgradeStatus = "deleted locally"
+	UpgradeNotGenerated UpgradeStatus = "no longer generated"
+	// UpgradeNotOwned files existed before `init` and are left to the user.
+	UpgradeNotOwned UpgradeStatus = "not owned"
+)
+
+// legacyGoVersion is the go directive scaffolds had before the version was
+// recorded in the marker.
+const legacyGoVersion = "1.22.0"
+
+type UpgradeResult struct {
+	OutputPath string
+	Status     UpgradeStatus
+}
+
+type UpgradeReport struct {
+	Dir         string
+	FromVersion string
+	ToVersion   string
+	Files       []UpgradeResult
+}
+
+func (r UpgradeReport) Conflicts() []string {
+	var paths []string
+	for _, file := range r.Files {
+		if file.Status == UpgradeConflict {
+			paths = append(paths, file.OutputPath)
+		}
+	}
+	return paths
+}
+
+// Upgrade re-renders the scaffold in dir with the templates of the version
+// recorded in its marker (the pristine base) and with the current templates,
+// then three-way merges the template changes into the user's files. Until
+// version is released and frozen, its base is the current templates.
+func Upgrade(dir, version string) (UpgradeReport, error) {
+	return upgrade(dir, version, func(v string) (fs.FS, error) {
+		fsys, err := templates.Release(v)
+		if err != nil && v == version {
+			return templates.FS, nil
+		}
+		return fsys, err
+	})
+}
+
+// upgrade is Upgrade with the frozen templates of a version coming from
+// release.
+func upgrade(dir, version string, release func(version string) (fs.FS, error)) (UpgradeReport, error) {
+	markerPath := filepath.Join(dir, spec.MarkerFileName)
+	marker, err := spec.ReadMarker(markerPath)
+	if err != nil {
+		return UpgradeReport{}, err
+	}
+	if errs := spec.ValidateMarker(marker); len(errs) > 0 {
+		return UpgradeReport{}, fmt.Errorf("invalid marker %s: %w", markerPath, errors.Join(errs...))
+	}
+
+	project := spec.ProjectSpec{
+		Name:      marker.Spec.Name,
+		Module:    marker.Spec.Module,
+		Dir:       dir,
+		HTTPPort:  marker.Spec.HTTPPort,
+		GoVersion: marker.Spec.GoVersion,
+		Vars:      marker.Spec.Vars,
+	}
+	if project.GoVersion == "" {
+		project.GoVersion = legacyGoVersion
+	}
+	pack, err := EmbeddedPack(marker.TemplatePack)
+	if err != nil {
+		return UpgradeReport{}, fmt.Errorf("upgrade supports embedded template packs only: %w", err)
+	}
+	project.Features = ExactFeatures(pack, marker.Spec.Features)
+
+	baseFS, err := release(marker.Version)
+	if err != nil {
+		return UpgradeReport{}, fmt.Errorf("cannot upgrade from %s: %w (known versions: %s)", marker.Version, err, strings.Join(templates.ReleaseVersions(), ", "))
+	}
+	base, err := planRelease(baseFS, project, marker)
+	if err != nil {
+		return UpgradeReport{}, err
+	}
+
+	current, err := Plan(project, version)
+	if err != nil {
+		return UpgradeReport{}, err
+	}
+
+	return upgradeFrom(base, current, marker.Files, marker.Version, version)
+}
+
+// planRelease renders the scaffold's pristine base from fsys, the frozen
+// templates of the marker's version, through the same pipeline as `new`.
+func planRelease(fsys fs.FS, project spec.ProjectSpec, marker spec.Marker) (RenderPlan, error) {
+	pack, err := releasePack(fsys, marker.TemplatePack, nil)
+	if err != nil {
+		return RenderPlan{}, fmt.Errorf("load %s templates: %w", marker.Version, err)
+	}
+
+	// A release only knows the variables and features it declared.
+	project.Vars = map[string]string{}
+	for _, variable := range pack.Variables {
+		if value, ok := marker.Spec.Vars[variable.Name]; ok {
+			project.Vars[variable.Name] = value
+		}
+	}
+	var enabled []string
+	for _, feature := range pack.Features {
+		if slices.Contains(marker.Spec.Features, feature.Name) {
+			enabled = append(enabled, feature.Name)
+		}
+	}
+	project.Features = ExactFeatures(pack, enabled)
+
+	base, err := planPack(pack, project, marker.Version)
+	if err != nil {
+		return RenderPlan{}, fmt.Errorf("render %s templates: %w", marker.Version, err)
+	}
+	return base, nil
+}
+
+// releasePack loads templatePack from a frozen release. Releases frozen with
+// their pack.json load like embedded packs; older ones have none and get a
+// manifest from releaseManifest.
+func releasePack(fsys fs.FS, templatePack string, chain []string) (Pack, error) {
+	chain, err := extendChain(chain, templatePack)
+	if err != nil {
+		return Pack{}, err
+	}
+	if !fs.ValidPath(templatePack) || templatePack == "." {
+		return Pack{}, fmt.Errorf("unknown template pack: %s", templatePack)
+	}
+
+	if _, err := fs.Stat(fsys, path.Join(templatePack, templates.PackManifestFile)); errors.Is(err, fs.ErrNotExist) {
+		entries, err := releaseManifest(fsys, templatePack)
+		if err != nil {
+			return Pack{}, err
+		}
+		return Pack{Name: templatePack, Origin: EmbeddedOrigin, FS: fsys, Entries: entries, Layers: []string{templatePack}}, nil
+	} else if err != nil {
+		return Pack{}, fmt.Errorf("released template pack %s: %w", templatePack, err)
+	}
+
+	manifest, err := templates.ReadPackManifest(fsys, templatePack)
+	if err != nil {
+		return Pack{}, fmt.Errorf("released template pack %s: %w", templatePack, err)
+	}
+	var base *Pack
+	if manifest.Extends != "" {
+		if isPackPath(manifest.Extends) {
+			return Pack{}, fmt.Errorf("released template pack %s: can only extend embedded packs, not %s", templatePack, manifest.Extends)
+		}
+		extended, err := releasePack(fsys, manifest.Extends, chain)
+		if err != nil {
+			return Pack{}, fmt.Errorf("released template pack %s: extends: %w", templatePack, err)
+		}
+		base = &extended
+	}
+
+	pack, err := packFromManifest(fsys, templatePack, manifest, base)
+	if err != nil {
+		return Pack{}, fmt.Errorf("released template pack %s: %w", templatePack, err)
+	}
+	pack.Origin = EmbeddedOrigin
+	return pack, nil
+}
+
+// upgradeFrom merges the change from base to current into the files on disk.
+// owned is the marker's files map: outputs the base generated but the marker
+// does not list were there before `init` and stay untouched and unrecorded. A
+// nil owned (markers that predate it) means every output is the tool's.
+func upgradeFrom(base, current RenderPlan, owned map[string]string, fromVersion, toVersion string) (UpgradeReport, error) {
+	report := UpgradeReport{
+		Dir:         current.Dir,
+		FromVersion: fromVersion,
+		ToVersion:   toVersion,
+	}
+
+	pristine := make(map[string][]byte, len(base.Files))
+	for _, file := range base.Files {
+		pristine[file.OutputPath] = file.Content
+	}
+
+	labels := MergeLabels{
+		Ours:   "local",
+		Base:   "gokit-scaffold " + fromVersion,
+		Theirs: "gokit-scaffold " + toVersion,
+	}
+
+	notOwned := map[string]bool{}
+	for _, file := range current.Files {
+		_, inBase := pristine[file.OutputPath]
+		if _, ok := owned[file.OutputPath]; owned != nil && inBase && !ok && file.OutputPath != spec.MarkerFileName {
+			notOwned[file.OutputPath] = true
+		}
+	}
+
+	var writes []PlannedFile
+	generated := make(map[string]bool, len(current.Files))
+	for _, file := range current.Files {
+		generated[file.OutputPath] = true
+		if notOwned[file.OutputPath] {
+			report.Files = append(report.Files, UpgradeResult{OutputPath: file.OutputPath, Status: UpgradeNotOwned})
+			continue
+		}
+		if file.OutputPath == spec.MarkerFileName && len(notOwned) > 0 {
+			var err error
+			if file, err = pruneMarker(file, notOwned); err != nil {
+				return UpgradeReport{}, err
+			}
+		}
+
+		local, err := os.ReadFile(filepath.Join(current.Dir, filepath.Clean(file.OutputPath)))
+		missing := errors.Is(err, os.ErrNotExist)
+		if err != nil && !missing {
+			return UpgradeReport{}, fmt.Errorf("read %s: %w", file.OutputPath, err)
+		}
+		baseContent, inBase := pristine[file.OutputPath]
+
+		status := UpgradeUnchanged
+		content := file.Content
+		switch {
+		case file.OutputPath == spec.MarkerFileName:
+			if missing || !bytes.Equal(local, file.Content) {
+				status = UpgradeUpdated
+			}
+		case missing && inBase:
+			status = UpgradeDeleted
+		case missing:
+			status = UpgradeAdded
+		case bytes.Equal(local, file.Content):
+			status = UpgradeUnchanged
+		case inBase && bytes.Equal(baseContent, file.Content):
+			status = UpgradeUnchanged
+		case inBase && bytes.Equal(local, baseContent):
+			status = UpgradeUpdated
+		default:
+			merged, conflict := Merge3(baseContent, local, file.Content, labels)
+			content = merged
+			status = UpgradeMerged
+			if conflict {
+				status = UpgradeConflict
+			}
+		}
+
+		if status != UpgradeUnchanged && status != UpgradeDeleted {
+			writes = append(writes, PlannedFile{
+				TemplatePath: file.TemplatePath,
+				OutputPath:   file.OutputPath,
+				Content:      content,
+				Mode:         file.Mode,
+			})
+		}
+		report.Files = append(report.Files, UpgradeResult{OutputPath: file.OutputPath, Status: status})
+	}
+
+	for _, file := range base.Files {
+		if !generated[file.OutputPath] {
+			report.Files = append(report.Files, UpgradeResult{OutputPath: file.OutputPath, Status: UpgradeNotGenerated})
+		}
+	}
+	sort.Slice(report.Files, func(i, j int) bool {
+		return report.Files[i].OutputPath < report.Files[j].OutputPath
+	})
+
+	if len(writes) > 0 {
+		if _, err := writePlan(RenderPlan{Dir: current.Dir, Files: writes}, ConflictOverwrite); err != nil {
+			return UpgradeReport{}, err
+		}
+	}
+
+	return report, nil
+}
+
+// releaseManifest rebuilds the manifest of a release frozen without its
+// pack.json, which mapped every `<path>.tmpl` to the output `<path>`.
+func releaseManifest(fsys fs.FS, templatePack string) ([]ManifestEntry, error) {
+	var entries []ManifestEntry
+	err := fs.WalkDir(fsys, templatePack, func(p string, d fs.DirEntry, walkErr error) error {
+		if walkErr != nil {
+			return walkErr
+		}
+		if d.IsDir() || !strings.HasSuffix(p, ".tmpl") {
+			return nil
+		}
+		entries = append(entries, ManifestEntry{
+			TemplatePath: p,
+			OutputPath:   strings.TrimSuffix(strings.TrimPrefix(p, templatePack+"/"), ".tmpl"),
+		})
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("read released template pack %s: %w", templatePack, err)
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].OutputPath < entries[j].OutputPath
+	})
+	return entries, nil
+}
@@ -0,0 +1,313 @@
+package generator
+
+import (
+	"bytes"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/ridzuwary/gokit-scaffold/internal/spec"
+	"github.com/ridzuwary/gokit-scaffold/templates"
+)
+
+func TestMerge3(t *testing.T) {
+	labels := MergeLabels{Ours: "local", Base: "base", Theirs: "new"}
+
+	cases := []struct {
+		name         string
+		base         string
+		ours         string
+		theirs       string
+		want         string
+		wantConflict bool
+	}{
+		{
+			name:   "theirs only",
+			base:   "a\nb\nc\n",
+			ours:   "a\nb\nc\n",
+			theirs: "a\nB\nc\n",
+			want:   "a\nB\nc\n",
+		},
+		{
+			name:   "disjoint edits",
+			base:   "a\nb\nc\nd\ne\n",
+			ours:   "a\nlocal\nb\nc\nd\ne\n",
+			theirs: "a\nb\nc\nd\nE\n",
+			want:   "a\nlocal\nb\nc\nd\nE\n",
+		},
+		{
+			name:   "same edit on both sides",
+			base:   "a\nb\n",
+			ours:   "a\nx\n",
+			theirs: "a\nx\n",
+			want:   "a\nx\n",
+		},
+		{
+			name:         "overlapping edits",
+			base:         "a\nb\nc\n",
+			ours:         "a\nmine\nc\n",
+			theirs:       "a\ntheirs\nc\n",
+			want:         "a\n<<<<<<< local\nmine\n||||||| base\nb\n=======\ntheirs\n>>>>>>> new\nc\n",
+			wantConflict: true,
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			got, conflict := Merge3([]byte(tc.base), []byte(tc.ours), []byte(tc.theirs), labels)
+			if conflict != tc.wantConflict {
+				t.Fatalf("conflict = %v, want %v", conflict, tc.wantConflict)
+			}
+			if string(got) != tc.want {
+				t.Fatalf("merge mismatch:\ngot:\n%s\nwant:\n%s", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestUpgradeFromMergesTemplateChanges(t *testing.T) {
+	dir := t.TempDir()
+	local := map[string]string{
+		"pristine.go": "package a\n\nconst timeout = 5\n",
+		"edited.go":   "package a\n\n// user notes\nfunc handler() {}\n\nconst timeout = 5\n",
+		"clash.go":    "const timeout = 7\n",
+	}
+	for rel, content := range local {
+		if err := os.WriteFile(filepath.Join(dir, rel), []byte(content), 0o644); err != nil {
+			t.Fatalf("seed %s: %v", rel, err)
+		}
+	}
+
+	base := RenderPlan{Dir: dir, Files: []PlannedFile{
+		{OutputPath: "clash.go", Content: []byte("const timeout = 5\n")},
+		{OutputPath: "deleted.go", Content: []byte("package a\n")},
+		{OutputPath: "edited.go", Content: []byte("package a\n\nfunc handler() {}\n\nconst timeout = 5\n")},
+		{OutputPath: "pristine.go", Content: []byte("package a\n\nconst timeout = 5\n")},
+	}}
+	current := RenderPlan{Dir: dir, Files: []PlannedFile{
+		{OutputPath: "added.go", Content: []byte("package a\n")},
+		{OutputPath: "clash.go", Content: []byte("const timeout = 10\n")},
+		{OutputPath: "deleted.go", Content: []byte("package a // changed\n")},
+		{OutputPath: "edited.go", Content: []byte("package a\n\nfunc handler() {}\n\nconst timeout = 10\n")},
+		{OutputPath: "pristine.go", Content: []byte("package a\n\nconst timeout = 10\n")},
+	}}
+
+	report, err := upgradeFrom(base, current, nil, "0.1.0", "0.2.0")
+	if err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+
+	want := map[string]UpgradeStatus{
+		"added.go":    UpgradeAdded,
+		"clash.go":    UpgradeConflict,
+		"deleted.go":  UpgradeDeleted,
+		"edited.go":   UpgradeMerged,
+		"pristine.go": UpgradeUpdated,
+	}
+	for _, file := range report.Files {
+		if want[file.OutputPath] != file.Status {
+			t.Fatalf("%s: status %q, want %q", file.OutputPath, file.Status, want[file.OutputPath])
+		}
+	}
+
+	read := func(rel string) string {
+		content, err := os.ReadFile(filepath.Join(dir, rel))
+		if err != nil {
+			t.Fatalf("read %s: %v", rel, err)
+		}
+		return string(content)
+	}
+	if got := read("edited.go"); got != "package a\n\n// user notes\nfunc handler() {}\n\nconst timeout = 10\n" {
+		t.Fatalf("unexpected merge result for edited.go:\n%s", got)
+	}
+	if got := read("clash.go"); !strings.Contains(got, "<<<<<<< local") || !strings.Contains(got, ">>>>>>> gokit-scaffold 0.2.0") {
+		t.Fatalf("expected conflict markers in clash.go, got:\n%s", got)
+	}
+	if _, err := os.Stat(filepath.Join(dir, "deleted.go")); !os.IsNotExist(err) {
+		t.Fatalf("expected deleted.go to stay deleted, stat err: %v", err)
+	}
+}
+
+func TestUpgradeFromFrozenPack(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hello-api")
+	project := spec.ProjectSpec{
+		Name:      "hello-api",
+		Module:    "github.com/example/hello-api",
+		Dir:       dir,
+		HTTPPort:  8080,
+		GoVersion: "1.22.0",
+		Vars:      map[string]string{"shutdown_timeout_seconds": "30"},
+		Features:  map[string]bool{"docker": true, "metrics": true},
+	}
+	if _, err := Generate(project, Options{Version: "0.1.1"}); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	readme := filepath.Join(dir, "README.md")
+	edited := []byte("# hello-api\n\nOur notes.\n")
+	if err := os.WriteFile(readme, edited, 0o644); err != nil {
+		t.Fatalf("edit README.md: %v", err)
+	}
+
+	report, err := upgrade(dir, "0.1.2", frozenRelease(t, "0.1.1"))
+	if err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+
+	for _, file := range report.Files {
+		want := UpgradeUnchanged
+		if file.OutputPath == spec.MarkerFileName {
+			want = UpgradeUpdated
+		}
+		if file.Status != want {
+			t.Fatalf("%s: status %q, want %q", file.OutputPath, file.Status, want)
+		}
+	}
+	for _, rel := range []string{".dockerignore", "internal/httpserver/metrics.go"} {
+		if !slices.ContainsFunc(report.Files, func(r UpgradeResult) bool { return r.OutputPath == rel }) {
+			t.Fatalf("expected %s in the report, got %+v", rel, report.Files)
+		}
+	}
+	if got, err := os.ReadFile(readme); err != nil || !bytes.Equal(got, edited) {
+		t.Fatalf("README.md changed: %q, %v", got, err)
+	}
+}
+
+func TestUpgradeAfterInitLeavesUserFiles(t *testing.T) {
+	dir := t.TempDir()
+	existing := map[string]string{
+		"go.mod":    "module github.com/acme/billing\n\ngo 1.22\n\nrequire github.com/acme/money v1.2.0\n",
+		"README.md": "# Billing\n",
+	}
+	for rel, content := range existing {
+		if err := os.WriteFile(filepath.Join(dir, rel), []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", rel, err)
+		}
+	}
+	project := spec.ProjectSpec{
+		Name:      "billing",
+		Module:    "github.com/acme/billing",
+		Dir:       dir,
+		HTTPPort:  8080,
+		GoVersion: "1.22",
+		Force:     true,
+	}
+	if _, err := Init(project, Options{Version: "0.1.1"}); err != nil {
+		t.Fatalf("init: %v", err)
+	}
+
+	report, err := upgrade(dir, "0.1.2", frozenRelease(t, "0.1.1"))
+	if err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	for _, file := range report.Files {
+		_, userFile := existing[file.OutputPath]
+		if userFile && file.Status != UpgradeNotOwned {
+			t.Fatalf("%s: status %q, want %q", file.OutputPath, file.Status, UpgradeNotOwned)
+		}
+		if file.Status == UpgradeConflict || file.Status == UpgradeMerged {
+			t.Fatalf("%s: unexpected status %q", file.OutputPath, file.Status)
+		}
+	}
+	for rel, want := range existing {
+		got, err := os.ReadFile(filepath.Join(dir, rel))
+		if err != nil || string(got) != want {
+			t.Fatalf("%s was modified: %q, %v", rel, got, err)
+		}
+	}
+
+	marker, err := spec.ReadMarker(filepath.Join(dir, spec.MarkerFileName))
+	if err != nil {
+		t.Fatalf("read marker: %v", err)
+	}
+	for rel := range existing {
+		if _, ok := marker.Files[rel]; ok {
+			t.Fatalf("upgrade recorded user-owned %s in the marker", rel)
+		}
+	}
+	if _, ok := marker.Files["cmd/server/main.go"]; !ok {
+		t.Fatalf("expected generated files to stay recorded: %v", marker.Files)
+	}
+}
+
+// frozenRelease serves the current embedded pack as the release of version,
+// as `cp -a templates/service-http templates/releases/<version>/` freezes it.
+func frozenRelease(t *testing.T, version string) func(string) (fs.FS, error) {
+	return func(v string) (fs.FS, error) {
+		if v != version {
+			t.Fatalf("asked for the templates of %s, want %s", v, version)
+		}
+		return templates.FS, nil
+	}
+}
+
+func TestUpgradeFreshScaffoldKeepsLocalEdits(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hello-api")
+	project := spec.ProjectSpec{
+		Name:     "hello-api",
+		Module:   "github.com/example/hello-api",
+		Dir:      dir,
+		HTTPPort: 8080,
+		Features: map[string]bool{"docker": true},
+	}
+	if _, err := Generate(project, Options{Version: spec.ToolVersion}); err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	gomod := filepath.Join(dir, "go.mod")
+	content, err := os.ReadFile(gomod)
+	if err != nil {
+		t.Fatalf("read go.mod: %v", err)
+	}
+	edited := append(content, []byte("\nrequire github.com/acme/money v1.2.0\n")...)
+	if err := os.WriteFile(gomod, edited, 0o644); err != nil {
+		t.Fatalf("edit go.mod: %v", err)
+	}
+
+	report, err := Upgrade(dir, spec.ToolVersion)
+	if err != nil {
+		t.Fatalf("upgrade: %v", err)
+	}
+	if conflicts := report.Conflicts(); len(conflicts) > 0 {
+		t.Fatalf("the base of %s is not what new writes: conflicts in %v", spec.ToolVersion, conflicts)
+	}
+	if got, err := os.ReadFile(gomod); err != nil || !bytes.Equal(got, edited) {
+		t.Fatalf("go.mod changed: %q, %v", got, err)
+	}
+}
+
+func TestCurrentReleaseMatchesTemplates(t *testing.T) {
+	frozen, err := templates.Release(spec.ToolVersion)
+	if err != nil {
+		t.Skipf("%s is not frozen yet", spec.ToolVersion)
+	}
+
+	seen := map[string]bool{}
+	err = fs.WalkDir(templates.FS, ".", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+		seen[p] = true
+		live, err := fs.ReadFile(templates.FS, p)
+		if err != nil {
+			return err
+		}
+		if shipped, err := fs.ReadFile(frozen, p); err != nil || !bytes.Equal(live, shipped) {
+			t.Errorf("%s differs from releases/%s (bump spec.ToolVersion before changing released templates)", p, spec.ToolVersion)
+		}
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("walk templates: %v", err)
+	}
+	err = fs.WalkDir(frozen, ".", func(p string, d fs.DirEntry, err error) error {
+		if err == nil && !d.IsDir() && !seen[p] {
+			t.Errorf("%s was removed after releases/%s was frozen", p, spec.ToolVersion)
+		}
+		return err
+	})
+	if err != nil {
+		t.Fatalf("walk releases/%s: %v", spec.ToolVersion, err)
+	}
+}
@@ -0,0 +1,73 @@
+package generator
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/ridzuwary/gokit-scaffold/templates"
+)
+
+// resolveVariables applies pack defaults to the user-supplied values and
+// converts them to their declared types for use as `.Vars` in templates. It
+// also returns the resolved values in textual form for the marker.
+func resolveVariables(declared []templates.PackVariable, values map[string]string) (map[string]string, map[string]any, error) {
+	known := make(map[string]bool, len(declared))
+	for _, variable := range declared {
+		known[variable.Name] = true
+	}
+
+	var unknown []string
+	for name := range values {
+		if !known[name] {
+			unknown = append(unknown, name)
+		}
+	}
+	if len(unknown) > 0 {
+		sort.Strings(unknown)
+		return nil, nil, fmt.Errorf("unknown variables: %s", strings.Join(unknown, ", "))
+	}
+
+	if len(declared) == 0 {
+		return nil, nil, nil
+	}
+
+	raw := make(map[string]string, len(declared))
+	typed := make(map[string]any, len(declared))
+	for _, variable := range declared {
+		value, ok := values[variable.Name]
+		if !ok {
+			def, err := variable.DefaultString()
+			if err != nil {
+				return nil, nil, err
+			}
+			if variable.Default == nil {
+				return nil, nil, fmt.Errorf("variable %s is required (set it with --var %s=<%s>)", variable.Name, variable.Name, variable.Type)
+			}
+			value = def
+		}
+
+		switch variable.Type {
+		case templates.VariableInt:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("variable %s must be an int, got %q", variable.Name, value)
+			}
+			typed[variable.Name] = n
+			value = strconv.Itoa(n)
+		case templates.VariableBool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return nil, nil, fmt.Errorf("variable %s must be a bool, got %q", variable.Name, value)
+			}
+			typed[variable.Name] = b
+			value = strconv.FormatBool(b)
+		default:
+			typed[variable.Name] = value
+		}
+		raw[variable.Name] = value
+	}
+
+	return raw, typed, nil
+}
@@ -0,0 +1,316 @@
+package outfs
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"iter"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errNotDir   = errors.New("not a directory")
+	errIsDir    = errors.New("is a directory")
+	errNotEmpty = errors.New("directory not empty")
+)
+
+// Mem is an FS held in memory. Its zero value is an empty file system ready
+// to use.
+type Mem struct {
+	mu    sync.Mutex
+	files map[string]*memFile
+}
+
+// memFile is a file or directory in a Mem. Its data is replaced, never
+// modified, so open files can keep reading it.
+type memFile struct {
+	data    []byte
+	mode    fs.FileMode
+	modTime time.Time
+}
+
+// NewMem returns an empty Mem.
+func NewMem() *Mem {
+	return &Mem{}
+}
+
+func (m *Mem) Open(name string) (fs.File, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	info, err := m.stat("open", name)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir() {
+		return &openMemFile{info: info, r: bytes.NewReader(info.file.data)}, nil
+	}
+
+	prefix := ""
+	if name != "." {
+		prefix = name + "/"
+	}
+	var entries []fs.DirEntry
+	for child, file := range m.files {
+		if rest, ok := strings.CutPrefix(child, prefix); ok && !strings.Contains(rest, "/") {
+			entries = append(entries, memInfo{name: rest, file: file})
+		}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name() < entries[j].Name() })
+	return &openMemDir{info: info, entries: entries}, nil
+}
+
+func (m *Mem) Lstat(name string) (fs.FileInfo, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.stat("lstat", name)
+}
+
+// stat looks name up; the root is an implicit directory.
+func (m *Mem) stat(op, name string) (memInfo, error) {
+	if !fs.ValidPath(name) {
+		return memInfo{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return memInfo{name: ".", file: &memFile{mode: fs.ModeDir | 0o755}}, nil
+	}
+	file, ok := m.files[name]
+	if !ok {
+		return memInfo{}, &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	}
+	return memInfo{name: path.Base(name), file: file}, nil
+}
+
+// Paths returns the regular files in m, sorted.
+func (m *Mem) Paths() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var paths []string
+	for name, file := range m.files {
+		if !file.mode.IsDir() {
+			paths = append(paths, name)
+		}
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+func (m *Mem) MkdirAll(name string, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrInvalid}
+	}
+	if name == "." {
+		return nil
+	}
+	for dir := range ancestors(name) {
+		if file, ok := m.files[dir]; ok {
+			if !file.mode.IsDir() {
+				return &fs.PathError{Op: "mkdir", Path: name, Err: errNotDir}
+			}
+			continue
+		}
+		m.set(dir, &memFile{mode: fs.ModeDir | perm.Perm(), modTime: time.Now()})
+	}
+	return nil
+}
+
+func (m *Mem) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if err := m.checkParent("write", name); err != nil {
+		return err
+	}
+	if file, ok := m.files[name]; ok && file.mode.IsDir() {
+		return &fs.PathError{Op: "write", Path: name, Err: errIsDir}
+	}
+	m.set(name, &memFile{
+		data:    append([]byte(nil), data...),
+		mode:    perm.Perm(),
+		modTime: time.Now(),
+	})
+	return nil
+}
+
+func (m *Mem) Rename(oldname, newname string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !fs.ValidPath(oldname) || oldname == "." {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrInvalid}
+	}
+	file, ok := m.files[oldname]
+	if !ok {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrNotExist}
+	}
+	if err := m.checkParent("rename", newname); err != nil {
+		return err
+	}
+	if newname == oldname || strings.HasPrefix(newname, oldname+"/") {
+		return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrInvalid}
+	}
+	if existing, ok := m.files[newname]; ok {
+		if existing.mode.IsDir() != file.mode.IsDir() || m.hasChildren(newname) {
+			return &fs.PathError{Op: "rename", Path: oldname, Err: fs.ErrExist}
+		}
+	}
+
+	moved := map[string]*memFile{newname: file}
+	for name, child := range m.files {
+		if rest, ok := strings.CutPrefix(name, oldname+"/"); ok {
+			moved[newname+"/"+rest] = child
+			delete(m.files, name)
+		}
+	}
+	delete(m.files, oldname)
+	for name, file := range moved {
+		m.set(name, file)
+	}
+	return nil
+}
+
+func (m *Mem) Remove(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if _, ok := m.files[name]; !ok {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrNotExist}
+	}
+	if m.hasChildren(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: errNotEmpty}
+	}
+	delete(m.files, name)
+	return nil
+}
+
+// RemoveAll removes name and everything under it. Like os.RemoveAll, a
+// missing name is not an error.
+func (m *Mem) RemoveAll(name string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "remove", Path: name, Err: fs.ErrInvalid}
+	}
+	for existing := range m.files {
+		if name == "." || existing == name || strings.HasPrefix(existing, name+"/") {
+			delete(m.files, existing)
+		}
+	}
+	return nil
+}
+
+// checkParent reports an error unless name is valid and its parent is an
+// existing directory.
+func (m *Mem) checkParent(op, name string) error {
+	if !fs.ValidPath(name) || name == "." {
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	parent := path.Dir(name)
+	if parent == "." {
+		return nil
+	}
+	file, ok := m.files[parent]
+	switch {
+	case !ok:
+		return &fs.PathError{Op: op, Path: name, Err: fs.ErrNotExist}
+	case !file.mode.IsDir():
+		return &fs.PathError{Op: op, Path: name, Err: errNotDir}
+	}
+	return nil
+}
+
+func (m *Mem) hasChildren(dir string) bool {
+	for name := range m.files {
+		if strings.HasPrefix(name, dir+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+func (m *Mem) set(name string, file *memFile) {
+	if m.files == nil {
+		m.files = map[string]*memFile{}
+	}
+	m.files[name] = file
+}
+
+// ancestors yields name's directories from the top down, then name itself.
+func ancestors(name string) iter.Seq[string] {
+	return func(yield func(string) bool) {
+		for i := 0; i < len(name); i++ {
+			if name[i] == '/' && !yield(name[:i]) {
+				return
+			}
+		}
+		yield(name)
+	}
+}
+
+// memInfo describes a memFile as both fs.FileInfo and fs.DirEntry.
+type memInfo struct {
+	name string
+	file *memFile
+}
+
+func (i memInfo) Name() string               { return i.name }
+func (i memInfo) Size() int64                { return int64(len(i.file.data)) }
+func (i memInfo) Mode() fs.FileMode          { return i.file.mode }
+func (i memInfo) ModTime() time.Time         { return i.file.modTime }
+func (i memInfo) IsDir() bool                { return i.file.mode.IsDir() }
+func (i memInfo) Sys() any                   { return nil }
+func (i memInfo) Type() fs.FileMode          { return i.file.mode.Type() }
+func (i memInfo) Info() (fs.FileInfo, error) { return i, nil }
+
+type openMemFile struct {
+	info memInfo
+	r    *bytes.Reader
+}
+
+func (f *openMemFile) Stat() (fs.FileInfo, error) { return f.info, nil }
+func (f *openMemFile) Read(b []byte) (int, error) { return f.r.Read(b) }
+func (f *openMemFile) Close() error               { return nil }
+
+func (f *openMemFile) Seek(offset int64, whence int) (int64, error) {
+	return f.r.Seek(offset, whence)
+}
+
+func (f *openMemFile) ReadAt(b []byte, offset int64) (int, error) {
+	return f.r.ReadAt(b, offset)
+}
+
+// openMemDir lists the entries a directory had when it was opened.
+type openMemDir struct {
+	info    memInfo
+	entries []fs.DirEntry
+	offset  int
+}
+
+func (d *openMemDir) Stat() (fs.FileInfo, error) { return d.info, nil }
+func (d *openMemDir) Close() error               { return nil }
+
+func (d *openMemDir) Read([]byte) (int, error) {
+	return 0, &fs.PathError{Op: "read", Path: d.info.name, Err: errIsDir}
+}
+
+func (d *openMemDir) ReadDir(n int) ([]fs.DirEntry, error) {
+	rest := d.entries[d.offset:]
+	if n > 0 && len(rest) == 0 {
+		return nil, io.EOF
+	}
+	if n > 0 && n < len(rest) {
+		rest = rest[:n]
+	}
+	d.offset += len(rest)
+	return rest, nil
+}
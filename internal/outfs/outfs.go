@@ -0,0 +1,210 @@
+// Package outfs provides the writable file systems the generator writes
+// through: the local disk, the local disk confined to one directory, and
+// memory.
+package outfs
+
+import (
+	"crypto/rand"
+	"errors"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// FS is a writable file system. Names are slash-separated paths relative to
+// its root, valid per fs.ValidPath, as with fs.FS.
+type FS interface {
+	fs.FS
+	// Lstat describes name without following a final symbolic link.
+	Lstat(name string) (fs.FileInfo, error)
+	MkdirAll(name string, perm fs.FileMode) error
+	// WriteFile creates or replaces name. Readers see the old content or the
+	// new, never a partial write.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Rename(oldname, newname string) error
+	Remove(name string) error
+	RemoveAll(name string) error
+}
+
+// Dir returns an FS for the directory dir on the local disk. Symbolic links
+// inside dir are followed wherever they point.
+func Dir(dir string) FS {
+	return osFS{dir: dir, FS: os.DirFS(dir)}
+}
+
+type osFS struct {
+	dir string
+	fs.FS
+}
+
+func (o osFS) path(op, name string) (string, error) {
+	if !fs.ValidPath(name) {
+		return "", &fs.PathError{Op: op, Path: name, Err: fs.ErrInvalid}
+	}
+	return filepath.Join(o.dir, filepath.FromSlash(name)), nil
+}
+
+func (o osFS) Lstat(name string) (fs.FileInfo, error) {
+	p, err := o.path("lstat", name)
+	if err != nil {
+		return nil, err
+	}
+	return os.Lstat(p)
+}
+
+func (o osFS) MkdirAll(name string, perm fs.FileMode) error {
+	p, err := o.path("mkdir", name)
+	if err != nil {
+		return err
+	}
+	return os.MkdirAll(p, perm)
+}
+
+func (o osFS) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	p, err := o.path("write", name)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, data, perm)
+}
+
+func (o osFS) Rename(oldname, newname string) error {
+	from, err := o.path("rename", oldname)
+	if err != nil {
+		return err
+	}
+	to, err := o.path("rename", newname)
+	if err != nil {
+		return err
+	}
+	return os.Rename(from, to)
+}
+
+func (o osFS) Remove(name string) error {
+	p, err := o.path("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.Remove(p)
+}
+
+func (o osFS) RemoveAll(name string) error {
+	p, err := o.path("remove", name)
+	if err != nil {
+		return err
+	}
+	return os.RemoveAll(p)
+}
+
+// writeFileAtomic writes to a temporary file next to path and renames it into
+// place.
+func writeFileAtomic(path string, content []byte, mode os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".gokit-scaffold-tmp-*")
+	if err != nil {
+		return err
+	}
+	tmpPath := tmp.Name()
+
+	defer func() {
+		_ = os.Remove(tmpPath)
+	}()
+
+	if _, err := tmp.Write(content); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(mode); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpPath, path)
+}
+
+// Root is an FS on the local disk that cannot reach outside its directory,
+// through ".." or through symbolic links.
+type Root struct {
+	root *os.Root
+	fs.FS
+}
+
+// OpenRoot opens the existing directory dir as a Root. Close releases it.
+func OpenRoot(dir string) (*Root, error) {
+	root, err := os.OpenRoot(dir)
+	if err != nil {
+		return nil, err
+	}
+	return &Root{root: root, FS: root.FS()}, nil
+}
+
+func (r *Root) Close() error {
+	return r.root.Close()
+}
+
+func (r *Root) Lstat(name string) (fs.FileInfo, error) {
+	return r.root.Lstat(name)
+}
+
+func (r *Root) MkdirAll(name string, perm fs.FileMode) error {
+	return r.root.MkdirAll(name, perm)
+}
+
+// WriteFile writes to a temporary file beside name and renames it into place,
+// as Dir does.
+func (r *Root) WriteFile(name string, data []byte, perm fs.FileMode) error {
+	if !fs.ValidPath(name) {
+		return &fs.PathError{Op: "write", Path: name, Err: fs.ErrInvalid}
+	}
+	tmpPath, tmp, err := r.createTemp(name)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = r.root.Remove(tmpPath)
+	}()
+
+	if _, err := tmp.Write(data); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Chmod(perm); err != nil {
+		_ = tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return r.root.Rename(tmpPath, name)
+}
+
+func (r *Root) createTemp(name string) (string, *os.File, error) {
+	for {
+		tmpPath := tempName(name, ".gokit-scaffold-tmp-")
+		tmp, err := r.root.OpenFile(tmpPath, os.O_RDWR|os.O_CREATE|os.O_EXCL, 0o600)
+		if errors.Is(err, fs.ErrExist) {
+			continue
+		}
+		return tmpPath, tmp, err
+	}
+}
+
+func (r *Root) Rename(oldname, newname string) error {
+	return r.root.Rename(oldname, newname)
+}
+
+func (r *Root) Remove(name string) error {
+	return r.root.Remove(name)
+}
+
+func (r *Root) RemoveAll(name string) error {
+	return r.root.RemoveAll(name)
+}
+
+// tempName returns an unused-looking sibling of name starting with prefix.
+func tempName(name, prefix string) string {
+	return path.Join(path.Dir(name), prefix+strings.ToLower(rand.Text()[:10]))
+}
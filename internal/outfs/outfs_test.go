@@ -0,0 +1,140 @@
+package outfs
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+)
+
+func TestFileSystems(t *testing.T) {
+	tests := []struct {
+		name string
+		open func(t *testing.T) FS
+	}{
+		{name: "dir", open: func(t *testing.T) FS { return Dir(t.TempDir()) }},
+		{name: "root", open: func(t *testing.T) FS {
+			root, err := OpenRoot(t.TempDir())
+			if err != nil {
+				t.Fatalf("open root: %v", err)
+			}
+			t.Cleanup(func() { _ = root.Close() })
+			return root
+		}},
+		{name: "mem", open: func(t *testing.T) FS { return NewMem() }},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fsys := tt.open(t)
+
+			if err := fsys.WriteFile("a/b.txt", []byte("x"), 0o644); !errors.Is(err, fs.ErrNotExist) {
+				t.Fatalf("write without parent: got %v, want ErrNotExist", err)
+			}
+			if err := fsys.MkdirAll("a/b", 0o755); err != nil {
+				t.Fatalf("mkdir: %v", err)
+			}
+			if err := fsys.WriteFile("a/b/c.sh", []byte("one"), 0o755); err != nil {
+				t.Fatalf("write: %v", err)
+			}
+			if err := fsys.WriteFile("a/b/c.sh", []byte("two"), 0o755); err != nil {
+				t.Fatalf("overwrite: %v", err)
+			}
+			if err := fsys.MkdirAll("a/b/c.sh/d", 0o755); err == nil {
+				t.Fatalf("expected mkdir under a file to fail")
+			}
+
+			if err := fsys.Rename("a/b", "moved"); err != nil {
+				t.Fatalf("rename: %v", err)
+			}
+			content, err := fs.ReadFile(fsys, "moved/c.sh")
+			if err != nil || string(content) != "two" {
+				t.Fatalf("read moved file: %q, %v", content, err)
+			}
+			info, err := fsys.Lstat("moved/c.sh")
+			if err != nil {
+				t.Fatalf("lstat: %v", err)
+			}
+			if info.Mode().Perm() != 0o755 {
+				t.Fatalf("mode %v, want 0755", info.Mode().Perm())
+			}
+			if _, err := fsys.Lstat("a/b"); !errors.Is(err, fs.ErrNotExist) {
+				t.Fatalf("old name still exists: %v", err)
+			}
+
+			if err := fsys.Remove("moved"); err == nil {
+				t.Fatalf("expected removing a non-empty directory to fail")
+			}
+			if err := fsys.RemoveAll("moved"); err != nil {
+				t.Fatalf("remove all: %v", err)
+			}
+			if err := fsys.RemoveAll("missing"); err != nil {
+				t.Fatalf("remove all of a missing name: %v", err)
+			}
+			if err := fsys.Remove("a"); err != nil {
+				t.Fatalf("remove: %v", err)
+			}
+			entries, err := fs.ReadDir(fsys, ".")
+			if err != nil {
+				t.Fatalf("read root: %v", err)
+			}
+			if len(entries) != 0 {
+				t.Fatalf("expected an empty root, found %s", entries[0].Name())
+			}
+
+			if err := fsys.WriteFile("../escape", []byte("x"), 0o644); err == nil {
+				t.Fatalf("expected a path outside the root to be rejected")
+			}
+		})
+	}
+}
+
+func TestRootRejectsSymlinkEscape(t *testing.T) {
+	dir := t.TempDir()
+	outside := t.TempDir()
+	if err := os.Symlink(outside, filepath.Join(dir, "link")); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+
+	root, err := OpenRoot(dir)
+	if err != nil {
+		t.Fatalf("open root: %v", err)
+	}
+	defer root.Close()
+
+	if err := root.WriteFile("link/x", []byte("x"), 0o644); err == nil {
+		t.Fatalf("expected a write through a symlink leaving the root to fail")
+	}
+	if entries, _ := os.ReadDir(outside); len(entries) != 0 {
+		t.Fatalf("wrote %s outside the root", entries[0].Name())
+	}
+}
+
+func TestMemPaths(t *testing.T) {
+	var mem Mem
+	if err := mem.MkdirAll("b", 0o755); err != nil {
+		t.Fatalf("mkdir: %v", err)
+	}
+	for _, name := range []string{"b/z", "a", "b/y"} {
+		if err := mem.WriteFile(name, nil, 0o644); err != nil {
+			t.Fatalf("write %s: %v", name, err)
+		}
+	}
+
+	if err := fstest.TestFS(&mem, "a", "b/y", "b/z"); err != nil {
+		t.Fatalf("fs.FS behaviour: %v", err)
+	}
+
+	got := mem.Paths()
+	want := []string{"a", "b/y", "b/z"}
+	if len(got) != len(want) {
+		t.Fatalf("paths %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Fatalf("paths %v, want %v", got, want)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package spec
+
+import (
+	"reflect"
+	"strings"
+)
+
+// SchemaField describes one marker field. Path is the dotted JSON path, Type a
+// JSON type such as "string" or "object of string".
+type SchemaField struct {
+	Path        string `json:"path"`
+	Type        string `json:"type"`
+	Required    bool   `json:"required"`
+	Description string `json:"description"`
+}
+
+// MarkerSchema lists the fields of the marker in declaration order, derived
+// from the json and doc tags of Marker and MarkerSpec. Nested objects are
+// listed before their fields.
+func MarkerSchema() []SchemaField {
+	return schemaFields(reflect.TypeOf(Marker{}), "")
+}
+
+func schemaFields(t reflect.Type, prefix string) []SchemaField {
+	var fields []SchemaField
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, opts, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, SchemaField{
+			Path:        prefix + name,
+			Type:        jsonType(field.Type),
+			Required:    !strings.Contains(opts, "omitempty"),
+			Description: field.Tag.Get("doc"),
+		})
+		if field.Type.Kind() == reflect.Struct {
+			fields = append(fields, schemaFields(field.Type, prefix+name+".")...)
+		}
+	}
+	return fields
+}
+
+func jsonType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.String:
+		return "string"
+	case reflect.Bool:
+		return "boolean"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "integer"
+	case reflect.Float32, reflect.Float64:
+		return "number"
+	case reflect.Slice, reflect.Array:
+		return "array of " + jsonType(t.Elem())
+	case reflect.Map:
+		return "object of " + jsonType(t.Elem())
+	case reflect.Struct:
+		return "object"
+	default:
+		return t.Kind().String()
+	}
+}
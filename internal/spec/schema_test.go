@@ -0,0 +1,32 @@
+package spec
+
+import "testing"
+
+func TestMarkerSchema(t *testing.T) {
+	fields := map[string]SchemaField{}
+	for _, field := range MarkerSchema() {
+		if field.Description == "" {
+			t.Fatalf("marker field %s has no doc tag", field.Path)
+		}
+		fields[field.Path] = field
+	}
+
+	want := map[string]SchemaField{
+		"tool":            {Type: "string", Required: true},
+		"template_layers": {Type: "array of string"},
+		"spec":            {Type: "object", Required: true},
+		"spec.http_port":  {Type: "integer", Required: true},
+		"spec.go_version": {Type: "string"},
+		"spec.vars":       {Type: "object of string"},
+		"files":           {Type: "object of string"},
+	}
+	for path, w := range want {
+		got, ok := fields[path]
+		if !ok {
+			t.Fatalf("schema is missing %s", path)
+		}
+		if got.Type != w.Type || got.Required != w.Required {
+			t.Fatalf("%s = %s (required %v), want %s (required %v)", path, got.Type, got.Required, w.Type, w.Required)
+		}
+	}
+}
@@ -1,118 +1,361 @@
 package spec
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"errors"
 	"fmt"
+	"go/version"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"regexp"
+	"runtime"
+	"sort"
+	"strconv"
 	"strings"
+
+	"github.com/ridzuwary/gokit-scaffold/templates"
 )
 
 const MarkerFileName = ".gokit-scaffold"
-const TemplatePackName = "service-http"
+const MarkerTool = "gokit-scaffold"
 
-var RequiredScaffoldFiles = []string{
-	"README.md",
-	"cmd/server/main.go",
-	"go.mod",
-	"internal/config/config.go",
-	"internal/httpserver/health.go",
-	"internal/httpserver/server.go",
-	"internal/logging/logging.go",
-}
+// ToolVersion is recorded in markers; upgrade needs the pristine templates of
+// every version it was ever released as.
+const ToolVersion = "0.2.0"
+
+// DefaultFileMode is the mode of generated files whose manifest entry does not
+// set one. The marker only records modes that differ from it.
+const DefaultFileMode fs.FileMode = 0o644
+
+var defaultPack = templates.MustEmbeddedManifest(templates.DefaultPack)
+
+var (
+	TemplatePackName      = defaultPack.Name
+	RequiredScaffoldFiles = defaultPack.Required
+)
 
 type ProjectSpec struct {
-	Name     string
-	Module   string
-	Dir      string
-	HTTPPort int
+	Name        string
+	Module      string
+	Dir         string
+	HTTPPort    int
+	GoVersion   string
+	Force       bool
+	TemplateDir string
+	Vars        map[string]string
+	Features    map[string]bool
+	// ExistingModule means Module was read from an existing go.mod rather
+	// than chosen, so it only has to be a valid module path.
+	ExistingModule bool
 }
 
+// Marker is the .gokit-scaffold file. The doc tags describe each field for
+// MarkerSchema.
 type Marker struct {
-	Tool         string     `json:"tool"`
-	Version      string     `json:"version"`
-	TemplatePack string     `json:"template_pack"`
-	Spec         MarkerSpec `json:"spec"`
+	Tool         string `json:"tool" doc:"scaffold generator identifier (gokit-scaffold)"`
+	Version      string `json:"version" doc:"tool version used to generate the scaffold"`
+	TemplatePack string `json:"template_pack" doc:"template pack name"`
+	// TemplateDir is the absolute directory of a pack loaded with
+	// --template-dir; it is omitted for embedded packs.
+	TemplateDir string `json:"template_dir,omitempty" doc:"directory the template pack was loaded from (only for packs loaded from a directory)"`
+	// TemplateLayers is the chain of packs template_pack extends, base first
+	// and ending with template_pack. It is omitted for packs that extend none.
+	TemplateLayers []string          `json:"template_layers,omitempty" doc:"packs template_pack extends, base first (only for layered packs)"`
+	Spec           MarkerSpec        `json:"spec" doc:"the project settings the scaffold was generated with"`
+	Files          map[string]string `json:"files,omitempty" doc:"SHA-256 of every generated file as originally rendered, keyed by output path"`
+	Modes          map[string]string `json:"modes,omitempty" doc:"octal mode of generated files not written as 0644, keyed by output path"`
+	// Required is the pack's required list, recorded for packs loaded from a
+	// directory, which validate cannot read back.
+	Required []string `json:"required,omitempty" doc:"files validate requires (only for packs loaded from a directory that list them)"`
 }
 
 type MarkerSpec struct {
-	Name     string `json:"name"`
-	Module   string `json:"module"`
-	HTTPPort int    `json:"http_port"`
+	Name      string            `json:"name" doc:"service name (^[a-z][a-z0-9-]*$)"`
+	Module    string            `json:"module" doc:"Go module path"`
+	HTTPPort  int               `json:"http_port" doc:"HTTP listen port (1-65535)"`
+	GoVersion string            `json:"go_version,omitempty" doc:"Go version of the go.mod go directive"`
+	Vars      map[string]string `json:"vars,omitempty" doc:"template pack variables as resolved at generation time"`
+	Features  []string          `json:"features,omitempty" doc:"optional pack features that were enabled"`
+}
+
+type FileState string
+
+const (
+	FileUntouched FileState = "untouched"
+	FileModified  FileState = "modified"
+	FileDeleted   FileState = "deleted"
+)
+
+type FileDrift struct {
+	Path  string
+	State FileState
+	// WantMode is the mode the file was generated with; Mode is its current
+	// mode if its executable bits differ, or WantMode otherwise (and for
+	// deleted files and on Windows).
+	WantMode fs.FileMode
+	Mode     fs.FileMode
+}
+
+func (d FileDrift) ModeChanged() bool {
+	return d.Mode != d.WantMode
+}
+
+// ParseFileMode parses an octal permission string such as "0755".
+func ParseFileMode(s string) (fs.FileMode, error) {
+	mode, err := strconv.ParseUint(s, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("must be octal permission bits such as \"0755\", got %q", s)
+	}
+	if mode&0o400 == 0 {
+		return 0, fmt.Errorf("must be readable by the owner, got %q", s)
+	}
+	return fs.FileMode(mode), nil
+}
+
+func FormatFileMode(mode fs.FileMode) string {
+	return fmt.Sprintf("%04o", mode.Perm())
+}
+
+// MarkerMode returns the mode rel was generated with according to m.
+func MarkerMode(m Marker, rel string) fs.FileMode {
+	if recorded, ok := m.Modes[rel]; ok {
+		if mode, err := ParseFileMode(recorded); err == nil {
+			return mode
+		}
+	}
+	return DefaultFileMode
 }
 
 var (
 	nameRe   = regexp.MustCompile(`^[a-z][a-z0-9-]*$`)
 	moduleRe = regexp.MustCompile(`^[a-z0-9][a-z0-9._-]*(/[a-zA-Z0-9._-]+)+$`)
+	// existingModuleRe is the shape of any module path `go mod init` accepts,
+	// including single elements such as "myapp".
+	existingModuleRe = regexp.MustCompile(`^[a-zA-Z0-9_~-][a-zA-Z0-9._~-]*(/[a-zA-Z0-9_~-][a-zA-Z0-9._~-]*)*$`)
+	sha256Re         = regexp.MustCompile(`^[0-9a-f]{64}$`)
+	packRe           = regexp.MustCompile(`^[a-zA-Z0-9][a-zA-Z0-9._-]*$`)
+	varRe            = regexp.MustCompile(`^[a-z][a-z0-9_]*$`)
 )
 
 func (s *ProjectSpec) Validate() error {
-	if err := validateName(s.Name); err != nil {
+	if err := s.ValidateSettings(); err != nil {
 		return err
 	}
-	if err := validateModule(s.Module); err != nil {
+	if err := validateDir(s.Dir, s.Force); err != nil {
 		return err
 	}
-	if err := validateHTTPPort(s.HTTPPort); err != nil {
+
+	return nil
+}
+
+// ValidateSettings checks everything Validate does except the target
+// directory, for commands that do not write one.
+func (s *ProjectSpec) ValidateSettings() error {
+	if err := ValidateName(s.Name); err != nil {
+		return err
+	}
+	if s.ExistingModule {
+		if err := ValidateExistingModule(s.Module); err != nil {
+			return fmt.Errorf("module %q declared in go.mod %v", s.Module, err)
+		}
+	} else if err := ValidateModule(s.Module); err != nil {
 		return err
 	}
-	if err := validateDir(s.Dir); err != nil {
+	if err := ValidateHTTPPort(s.HTTPPort); err != nil {
 		return err
 	}
+	if s.GoVersion != "" {
+		if err := ValidateGoVersion(s.GoVersion); err != nil {
+			return fmt.Errorf("go version %v", err)
+		}
+	}
+	for name := range s.Vars {
+		if err := ValidateVariableName(name); err != nil {
+			return fmt.Errorf("variable %q %v", name, err)
+		}
+	}
+	for name := range s.Features {
+		if err := ValidateFeatureName(name); err != nil {
+			return fmt.Errorf("feature %q %v", name, err)
+		}
+	}
 
 	return nil
 }
 
+type ProblemKind string
+
+const (
+	// ProblemDir means the directory itself cannot be validated.
+	ProblemDir ProblemKind = "directory"
+	// ProblemMarker means the marker is missing, unreadable or invalid.
+	ProblemMarker      ProblemKind = "marker"
+	ProblemMissingFile ProblemKind = "missing file"
+	// ProblemFile means a required file exists but cannot be used.
+	ProblemFile ProblemKind = "file"
+)
+
+// Problem is a reason ValidateScaffoldDir rejects a directory. Path is the
+// directory for ProblemDir and relative to it otherwise.
+type Problem struct {
+	Kind ProblemKind
+	Path string
+	Err  error
+}
+
+func (p *Problem) Error() string {
+	return p.Err.Error()
+}
+
+func (p *Problem) Unwrap() error {
+	return p.Err
+}
+
+// ValidateScaffoldDir returns the problems with the scaffold in dir, each a
+// *Problem.
 func ValidateScaffoldDir(dir string) []error {
 	var errs []error
 	absDir, err := filepath.Abs(filepath.Clean(dir))
 	if err != nil {
-		return []error{fmt.Errorf("resolve directory: %w", err)}
+		return []error{&Problem{Kind: ProblemDir, Path: dir, Err: fmt.Errorf("resolve directory: %w", err)}}
 	}
 
 	info, err := os.Stat(absDir)
 	if err != nil {
 		if errors.Is(err, os.ErrNotExist) {
-			return []error{fmt.Errorf("directory not found: %s", absDir)}
+			err = fmt.Errorf("directory not found: %s", absDir)
+		} else {
+			err = fmt.Errorf("stat directory: %w", err)
 		}
-		return []error{fmt.Errorf("stat directory: %w", err)}
+		return []error{&Problem{Kind: ProblemDir, Path: absDir, Err: err}}
 	}
 	if !info.IsDir() {
-		return []error{fmt.Errorf("path is not a directory: %s", absDir)}
+		return []error{&Problem{Kind: ProblemDir, Path: absDir, Err: fmt.Errorf("path is not a directory: %s", absDir)}}
 	}
 
 	markerPath := filepath.Join(absDir, MarkerFileName)
-	marker, err := readMarker(markerPath)
+	marker, err := ReadMarker(markerPath)
 	if err != nil {
-		errs = append(errs, err)
+		errs = append(errs, &Problem{Kind: ProblemMarker, Path: MarkerFileName, Err: err})
 	}
 
 	if err == nil {
-		errs = append(errs, validateMarker(marker)...)
+		for _, markerErr := range ValidateMarker(marker) {
+			errs = append(errs, &Problem{Kind: ProblemMarker, Path: MarkerFileName, Err: markerErr})
+		}
 	}
 
-	for _, rel := range RequiredScaffoldFiles {
+	for _, rel := range requiredFiles(marker) {
 		path := filepath.Join(absDir, rel)
 		info, statErr := os.Stat(path)
 		if statErr != nil {
 			if errors.Is(statErr, os.ErrNotExist) {
-				errs = append(errs, fmt.Errorf("missing required file: %s (re-run `gokit-scaffold new` into a clean directory)", rel))
+				errs = append(errs, &Problem{Kind: ProblemMissingFile, Path: rel, Err: fmt.Errorf("missing required file: %s (re-run `gokit-scaffold new` into a clean directory)", rel)})
 				continue
 			}
-			errs = append(errs, fmt.Errorf("stat required file %s: %w", rel, statErr))
+			errs = append(errs, &Problem{Kind: ProblemFile, Path: rel, Err: fmt.Errorf("stat required file %s: %w", rel, statErr)})
 			continue
 		}
 		if info.IsDir() {
-			errs = append(errs, fmt.Errorf("required file is a directory: %s", rel))
+			errs = append(errs, &Problem{Kind: ProblemFile, Path: rel, Err: fmt.Errorf("required file is a directory: %s", rel)})
 		}
 	}
 
 	return errs
 }
 
-func readMarker(path string) (Marker, error) {
+// requiredFiles lists the files a scaffold must contain. Markers that predate
+// checksums get the stock layout. Otherwise every generated file is required,
+// narrowed to the pack's `required` list: the one the marker recorded, or the
+// embedded pack's.
+func requiredFiles(m Marker) []string {
+	if len(m.Files) == 0 {
+		return RequiredScaffoldFiles
+	}
+
+	required := m.Required
+	if len(required) == 0 {
+		if manifest, err := templates.EmbeddedManifest(m.TemplatePack); err == nil {
+			required = manifest.Required
+		}
+	}
+	var packRequired map[string]bool
+	if len(required) > 0 {
+		packRequired = make(map[string]bool, len(required))
+		for _, rel := range required {
+			packRequired[rel] = true
+		}
+	}
+
+	files := make([]string, 0, len(m.Files))
+	for rel := range m.Files {
+		if !validRelPath(rel) || (packRequired != nil && !packRequired[rel]) {
+			continue
+		}
+		files = append(files, rel)
+	}
+	sort.Strings(files)
+	return files
+}
+
+func generatedFiles(m Marker) []string {
+	files := make([]string, 0, len(m.Files))
+	for rel := range m.Files {
+		if validRelPath(rel) {
+			files = append(files, rel)
+		}
+	}
+	sort.Strings(files)
+	return files
+}
+
+func ScaffoldDrift(dir string) ([]FileDrift, error) {
+	marker, err := ReadMarker(filepath.Join(dir, MarkerFileName))
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []FileDrift
+	for _, rel := range generatedFiles(marker) {
+		want := marker.Files[rel]
+
+		file := FileDrift{Path: rel, State: FileUntouched, WantMode: MarkerMode(marker, rel)}
+		file.Mode = file.WantMode
+		path := filepath.Join(dir, filepath.FromSlash(rel))
+		content, err := os.ReadFile(path)
+		switch {
+		case errors.Is(err, os.ErrNotExist):
+			file.State = FileDeleted
+		case err != nil:
+			return nil, fmt.Errorf("read %s: %w", rel, err)
+		default:
+			sum := sha256.Sum256(content)
+			if hex.EncodeToString(sum[:]) != want {
+				file.State = FileModified
+			}
+			// Windows has no permission bits to compare. Elsewhere only the
+			// executable bits count: git keeps nothing else, and the rest
+			// depends on the umask of whoever checked the files out.
+			if runtime.GOOS != "windows" {
+				info, err := os.Stat(path)
+				if err != nil {
+					return nil, fmt.Errorf("stat %s: %w", rel, err)
+				}
+				if mode := info.Mode().Perm(); mode&0o111 != file.WantMode&0o111 {
+					file.Mode = mode
+				}
+			}
+		}
+		drift = append(drift, file)
+	}
+
+	return drift, nil
+}
+
+func ReadMarker(path string) (Marker, error) {
 	var marker Marker
 
 	content, err := os.ReadFile(path)
@@ -130,57 +373,171 @@ func readMarker(path string) (Marker, error) {
 	return marker, nil
 }
 
-func validateMarker(m Marker) []error {
+func ValidateMarker(m Marker) []error {
 	var errs []error
 
 	if strings.TrimSpace(m.Tool) == "" {
 		errs = append(errs, errors.New("marker field `tool` is required"))
-	} else if m.Tool != "gokit-scaffold" {
-		errs = append(errs, fmt.Errorf("marker field `tool` must be `gokit-scaffold`, got %q", m.Tool))
+	} else if m.Tool != MarkerTool {
+		errs = append(errs, fmt.Errorf("marker field `tool` must be `%s`, got %q", MarkerTool, m.Tool))
 	}
 	if strings.TrimSpace(m.Version) == "" {
 		errs = append(errs, errors.New("marker field `version` is required"))
 	}
 	if strings.TrimSpace(m.TemplatePack) == "" {
 		errs = append(errs, errors.New("marker field `template_pack` is required"))
-	} else if m.TemplatePack != TemplatePackName {
+	} else if len(m.Files) == 0 && m.TemplatePack != TemplatePackName {
 		errs = append(errs, fmt.Errorf("marker field `template_pack` must be %q, got %q", TemplatePackName, m.TemplatePack))
+	} else if err := ValidateTemplatePackName(m.TemplatePack); err != nil {
+		errs = append(errs, fmt.Errorf("marker field `template_pack` %v", err))
+	}
+	if len(m.TemplateLayers) > 0 {
+		for _, layer := range m.TemplateLayers {
+			if err := ValidateTemplatePackName(layer); err != nil {
+				errs = append(errs, fmt.Errorf("marker field `template_layers` entry %q %v", layer, err))
+			}
+		}
+		if last := m.TemplateLayers[len(m.TemplateLayers)-1]; last != m.TemplatePack {
+			errs = append(errs, fmt.Errorf("marker field `template_layers` must end with template_pack %q, got %q", m.TemplatePack, last))
+		}
 	}
-	if err := validateName(m.Spec.Name); err != nil {
+	if err := ValidateName(m.Spec.Name); err != nil {
 		errs = append(errs, fmt.Errorf("marker field `spec.name` %v", err))
 	}
-	if err := validateModule(m.Spec.Module); err != nil {
+	if err := ValidateExistingModule(m.Spec.Module); err != nil {
 		errs = append(errs, fmt.Errorf("marker field `spec.module` %v", err))
 	}
-	if err := validateHTTPPort(m.Spec.HTTPPort); err != nil {
+	if err := ValidateHTTPPort(m.Spec.HTTPPort); err != nil {
 		errs = append(errs, fmt.Errorf("marker field `spec.http_port` %v", err))
 	}
+	if m.Spec.GoVersion != "" {
+		if err := ValidateGoVersion(m.Spec.GoVersion); err != nil {
+			errs = append(errs, fmt.Errorf("marker field `spec.go_version` %v", err))
+		}
+	}
+
+	for name := range m.Spec.Vars {
+		if err := ValidateVariableName(name); err != nil {
+			errs = append(errs, fmt.Errorf("marker field `spec.vars` has invalid name %q: %v", name, err))
+		}
+	}
+	for _, name := range m.Spec.Features {
+		if err := ValidateFeatureName(name); err != nil {
+			errs = append(errs, fmt.Errorf("marker field `spec.features` has invalid name %q: %v", name, err))
+		}
+	}
+
+	paths := make([]string, 0, len(m.Files))
+	for rel := range m.Files {
+		paths = append(paths, rel)
+	}
+	sort.Strings(paths)
+	for _, rel := range paths {
+		if !validRelPath(rel) {
+			errs = append(errs, fmt.Errorf("marker field `files` has invalid path %q", rel))
+		} else if !sha256Re.MatchString(m.Files[rel]) {
+			errs = append(errs, fmt.Errorf("marker field `files.%s` must be a hex SHA-256, got %q", rel, m.Files[rel]))
+		}
+	}
+
+	for _, rel := range m.Required {
+		if !validRelPath(rel) {
+			errs = append(errs, fmt.Errorf("marker field `required` has invalid path %q", rel))
+		}
+	}
+
+	modes := make([]string, 0, len(m.Modes))
+	for rel := range m.Modes {
+		modes = append(modes, rel)
+	}
+	sort.Strings(modes)
+	for _, rel := range modes {
+		if _, ok := m.Files[rel]; !ok {
+			errs = append(errs, fmt.Errorf("marker field `modes` lists %q, which is not in `files`", rel))
+		} else if _, err := ParseFileMode(m.Modes[rel]); err != nil {
+			errs = append(errs, fmt.Errorf("marker field `modes.%s` %v", rel, err))
+		}
+	}
 
 	return errs
 }
 
-func validateName(name string) error {
+func validRelPath(rel string) bool {
+	return rel != "" && rel != "." && fs.ValidPath(rel)
+}
+
+func ValidateName(name string) error {
+	if !nameRe.MatchString(name) {
+		return errors.New("must match ^[a-z][a-z0-9-]*$")
+	}
+	return nil
+}
+
+func ValidateTemplatePackName(name string) error {
+	if !packRe.MatchString(name) {
+		return errors.New("must match ^[a-zA-Z0-9][a-zA-Z0-9._-]*$")
+	}
+	return nil
+}
+
+func ValidateVariableName(name string) error {
+	if !varRe.MatchString(name) {
+		return errors.New("must match ^[a-z][a-z0-9_]*$")
+	}
+	return nil
+}
+
+func ValidateFeatureName(name string) error {
 	if !nameRe.MatchString(name) {
 		return errors.New("must match ^[a-z][a-z0-9-]*$")
 	}
 	return nil
 }
 
-func validateModule(module string) error {
+func ValidateModule(module string) error {
 	if !moduleRe.MatchString(module) || strings.Contains(module, "..") {
-		return errors.New("is invalid")
+		return errors.New(`must be a module path such as github.com/acme/hello-api: a lowercase first element, then one or more "/"-separated elements of letters, digits, ".", "_" or "-", and no ".."`)
+	}
+	return nil
+}
+
+// ValidateExistingModule accepts the module paths of existing modules, which
+// need not look like a repository path: `init` and markers use it, since the
+// module was chosen before the scaffold.
+func ValidateExistingModule(module string) error {
+	if !existingModuleRe.MatchString(module) {
+		return errors.New(`must be a module path: "/"-separated elements of letters, digits, ".", "_", "~" or "-", none starting with "."`)
 	}
 	return nil
 }
 
-func validateHTTPPort(port int) error {
+func ValidateHTTPPort(port int) error {
 	if port <= 0 || port > 65535 {
 		return errors.New("must be between 1 and 65535")
 	}
 	return nil
 }
 
-func validateDir(dir string) error {
+// ValidateGoVersion accepts the versions a go.mod `go` line does, such as
+// "1.22", "1.22.0" or "1.23rc1", without the "go" prefix.
+func ValidateGoVersion(v string) error {
+	if strings.HasPrefix(v, "go") || !version.IsValid("go"+v) {
+		return fmt.Errorf("must be a Go release such as 1.22 or 1.22.0, got %q", v)
+	}
+	return nil
+}
+
+// DefaultGoVersion is the version of the toolchain the tool was built with,
+// or "" when that is not a release (a development build).
+func DefaultGoVersion() string {
+	v := strings.TrimPrefix(runtime.Version(), "go")
+	if ValidateGoVersion(v) != nil {
+		return ""
+	}
+	return v
+}
+
+func validateDir(dir string, allowNonEmpty bool) error {
 	if strings.TrimSpace(dir) == "" {
 		return errors.New("target directory is required")
 	}
@@ -214,8 +571,8 @@ func validateDir(dir string) error {
 	if err != nil {
 		return fmt.Errorf("read target directory: %w", err)
 	}
-	if len(entries) > 0 {
-		return errors.New("target directory is not empty")
+	if len(entries) > 0 && !allowNonEmpty {
+		return errors.New("target directory is not empty (use --force to write into it)")
 	}
 
 	return nil
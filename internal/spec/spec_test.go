@@ -1,9 +1,12 @@
 package spec
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 )
 
@@ -35,6 +38,37 @@ func TestProjectSpecValidate(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "single element module",
+			spec: ProjectSpec{
+				Name:     "hello-api",
+				Module:   "myapp",
+				Dir:      filepath.Join(baseDir, "single-module"),
+				HTTPPort: 8080,
+			},
+			wantError: true,
+		},
+		{
+			name: "existing single element module",
+			spec: ProjectSpec{
+				Name:           "myapp",
+				Module:         "myapp",
+				Dir:            filepath.Join(baseDir, "existing-module"),
+				HTTPPort:       8080,
+				ExistingModule: true,
+			},
+		},
+		{
+			name: "invalid existing module",
+			spec: ProjectSpec{
+				Name:           "myapp",
+				Module:         "my app/.hidden",
+				Dir:            filepath.Join(baseDir, "bad-existing-module"),
+				HTTPPort:       8080,
+				ExistingModule: true,
+			},
+			wantError: true,
+		},
 		{
 			name: "invalid port",
 			spec: ProjectSpec{
@@ -45,6 +79,27 @@ func TestProjectSpecValidate(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "go version",
+			spec: ProjectSpec{
+				Name:      "hello-api",
+				Module:    "github.com/example/hello-api",
+				Dir:       filepath.Join(baseDir, "go-version"),
+				HTTPPort:  8080,
+				GoVersion: "1.23rc1",
+			},
+		},
+		{
+			name: "invalid go version",
+			spec: ProjectSpec{
+				Name:      "hello-api",
+				Module:    "github.com/example/hello-api",
+				Dir:       filepath.Join(baseDir, "bad-go-version"),
+				HTTPPort:  8080,
+				GoVersion: "go1.22",
+			},
+			wantError: true,
+		},
 		{
 			name: "non-empty directory",
 			spec: ProjectSpec{
@@ -55,6 +110,16 @@ func TestProjectSpecValidate(t *testing.T) {
 			},
 			wantError: true,
 		},
+		{
+			name: "non-empty directory with force",
+			spec: ProjectSpec{
+				Name:     "hello-api",
+				Module:   "github.com/example/hello-api",
+				Dir:      filepath.Join(baseDir, "occupied"),
+				HTTPPort: 8080,
+				Force:    true,
+			},
+		},
 	}
 
 	if err := os.MkdirAll(filepath.Join(baseDir, "occupied"), 0o755); err != nil {
@@ -109,6 +174,9 @@ func TestValidateScaffoldDir(t *testing.T) {
 		}
 
 		for _, rel := range RequiredScaffoldFiles {
+			if rel == MarkerFileName {
+				continue
+			}
 			path := filepath.Join(dir, rel)
 			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 				t.Fatalf("mkdir %s: %v", path, err)
@@ -146,6 +214,9 @@ func TestValidateScaffoldDir(t *testing.T) {
 		}
 
 		for _, rel := range RequiredScaffoldFiles {
+			if rel == MarkerFileName {
+				continue
+			}
 			path := filepath.Join(dir, rel)
 			if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
 				t.Fatalf("mkdir %s: %v", path, err)
@@ -169,3 +240,84 @@ func TestValidateScaffoldDir(t *testing.T) {
 		}
 	})
 }
+
+func TestScaffoldDrift(t *testing.T) {
+	dir := t.TempDir()
+	stock := map[string]string{
+		"go.mod":                        "module github.com/example/hello-api\n",
+		"internal/httpserver/server.go": "package httpserver\n",
+		"README.md":                     "# hello-api\n",
+		"Makefile":                      "run:\n\tgo run ./cmd/hello-api\n",
+	}
+
+	marker := Marker{
+		Tool:         MarkerTool,
+		Version:      "0.1.0",
+		TemplatePack: TemplatePackName,
+		Spec: MarkerSpec{
+			Name:     "hello-api",
+			Module:   "github.com/example/hello-api",
+			HTTPPort: 8080,
+		},
+		Files: map[string]string{},
+		Modes: map[string]string{"internal/httpserver/server.go": "0755"},
+	}
+	for rel, content := range stock {
+		sum := sha256.Sum256([]byte(content))
+		marker.Files[rel] = hex.EncodeToString(sum[:])
+
+		path := filepath.Join(dir, rel)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("mkdir %s: %v", path, err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("write %s: %v", path, err)
+		}
+	}
+	content, err := json.MarshalIndent(marker, "", "  ")
+	if err != nil {
+		t.Fatalf("marshal marker: %v", err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, MarkerFileName), content, 0o644); err != nil {
+		t.Fatalf("write marker: %v", err)
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# edited\n"), 0o644); err != nil {
+		t.Fatalf("edit README.md: %v", err)
+	}
+	if err := os.Remove(filepath.Join(dir, "go.mod")); err != nil {
+		t.Fatalf("remove go.mod: %v", err)
+	}
+	// A umask 002 checkout: group write is not drift.
+	if err := os.Chmod(filepath.Join(dir, "Makefile"), 0o664); err != nil {
+		t.Fatalf("chmod Makefile: %v", err)
+	}
+
+	drift, err := ScaffoldDrift(dir)
+	if err != nil {
+		t.Fatalf("scaffold drift: %v", err)
+	}
+
+	want := []FileDrift{
+		{Path: "Makefile", State: FileUntouched, WantMode: 0o644, Mode: 0o644},
+		{Path: "README.md", State: FileModified, WantMode: 0o644, Mode: 0o644},
+		{Path: "go.mod", State: FileDeleted, WantMode: 0o644, Mode: 0o644},
+		{Path: "internal/httpserver/server.go", State: FileUntouched, WantMode: 0o755, Mode: 0o644},
+	}
+	if runtime.GOOS == "windows" {
+		want[3].Mode = want[3].WantMode
+	}
+	if len(drift) != len(want) {
+		t.Fatalf("got %d drift entries, want %d: %+v", len(drift), len(want), drift)
+	}
+	for i := range want {
+		if drift[i] != want[i] {
+			t.Fatalf("drift[%d] = %+v, want %+v", i, drift[i], want[i])
+		}
+	}
+
+	errs := ValidateScaffoldDir(dir)
+	if len(errs) != 1 {
+		t.Fatalf("expected only the deleted go.mod to fail validation, got %v", errs)
+	}
+}
@@ -0,0 +1,149 @@
+package spec
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// SpecFile is the JSON read by `new --spec`; every `new` flag except --spec and
+// --interactive has a key. A scaffold marker is accepted as well, in which case its `spec` block is used
+// and TemplatePack records the pack it was generated from.
+//
+// Features lists exactly the features to enable: nil means the pack defaults,
+// an empty list means none.
+type SpecFile struct {
+	Name          string            `json:"name,omitempty"`
+	Module        string            `json:"module,omitempty"`
+	Dir           string            `json:"dir,omitempty"`
+	OutputArchive string            `json:"output_archive,omitempty"`
+	HTTPPort      int               `json:"http_port,omitempty"`
+	GoVersion     string            `json:"go_version,omitempty"`
+	TemplateDir   string            `json:"template_dir,omitempty"`
+	Vars          map[string]string `json:"vars,omitempty"`
+	Features      []string          `json:"features,omitempty"`
+	Force         bool              `json:"force,omitempty"`
+	OnConflict    string            `json:"on_conflict,omitempty"`
+	Verify        bool              `json:"verify,omitempty"`
+	DryRun        bool              `json:"dry_run,omitempty"`
+	ShowContent   bool              `json:"show_content,omitempty"`
+
+	TemplatePack string `json:"-"`
+}
+
+// ReadSpecFile reads a spec file or marker. Unknown keys are all reported, by
+// JSON path, rather than ignored. A relative template_dir is resolved against
+// the file's directory so a checked-in spec can point at a pack next to it.
+func ReadSpecFile(path string) (SpecFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return SpecFile{}, fmt.Errorf("read spec file: %w", err)
+	}
+
+	var raw map[string]any
+	if err := json.Unmarshal(content, &raw); err != nil {
+		return SpecFile{}, fmt.Errorf("parse spec file %s: %w", path, err)
+	}
+
+	var file SpecFile
+	if _, isMarker := raw["spec"]; isMarker {
+		errs := unknownKeys(raw, reflect.TypeOf(Marker{}), "$")
+		if block, ok := raw["spec"].(map[string]any); ok {
+			errs = append(errs, unknownKeys(block, reflect.TypeOf(MarkerSpec{}), "$.spec")...)
+		}
+		if len(errs) > 0 {
+			return SpecFile{}, fmt.Errorf("spec file %s: %w", path, errors.Join(errs...))
+		}
+
+		var marker Marker
+		if err := json.Unmarshal(content, &marker); err != nil {
+			return SpecFile{}, fmt.Errorf("parse spec file %s: %w", path, err)
+		}
+		file = SpecFile{
+			Name:         marker.Spec.Name,
+			Module:       marker.Spec.Module,
+			HTTPPort:     marker.Spec.HTTPPort,
+			GoVersion:    marker.Spec.GoVersion,
+			Vars:         marker.Spec.Vars,
+			Features:     append([]string{}, marker.Spec.Features...),
+			TemplateDir:  marker.TemplateDir,
+			TemplatePack: marker.TemplatePack,
+		}
+	} else {
+		if errs := unknownKeys(raw, reflect.TypeOf(SpecFile{}), "$"); len(errs) > 0 {
+			return SpecFile{}, fmt.Errorf("spec file %s: %w", path, errors.Join(errs...))
+		}
+
+		// Variables may be written as JSON numbers and booleans; they are
+		// passed on in the same textual form as --var.
+		var typed struct {
+			SpecFile
+			Vars map[string]any `json:"vars,omitempty"`
+		}
+		if err := json.Unmarshal(content, &typed); err != nil {
+			return SpecFile{}, fmt.Errorf("parse spec file %s: %w", path, err)
+		}
+		file = typed.SpecFile
+		vars, err := varStrings(typed.Vars)
+		if err != nil {
+			return SpecFile{}, fmt.Errorf("spec file %s: %w", path, err)
+		}
+		file.Vars = vars
+	}
+
+	if file.TemplateDir != "" && !filepath.IsAbs(file.TemplateDir) {
+		file.TemplateDir = filepath.Join(filepath.Dir(path), file.TemplateDir)
+	}
+	return file, nil
+}
+
+func varStrings(values map[string]any) (map[string]string, error) {
+	if values == nil {
+		return nil, nil
+	}
+	vars := make(map[string]string, len(values))
+	for name, value := range values {
+		switch v := value.(type) {
+		case string:
+			vars[name] = v
+		case float64:
+			vars[name] = strconv.FormatFloat(v, 'f', -1, 64)
+		case bool:
+			vars[name] = strconv.FormatBool(v)
+		default:
+			return nil, fmt.Errorf("$.vars.%s must be a string, number or boolean", name)
+		}
+	}
+	return vars, nil
+}
+
+// unknownKeys reports the keys of obj that are not JSON fields of t, sorted.
+func unknownKeys(obj map[string]any, t reflect.Type, prefix string) []error {
+	known := map[string]bool{}
+	for i := 0; i < t.NumField(); i++ {
+		name, _, _ := strings.Cut(t.Field(i).Tag.Get("json"), ",")
+		if name != "" && name != "-" {
+			known[name] = true
+		}
+	}
+
+	var unknown []string
+	for key := range obj {
+		if !known[key] {
+			unknown = append(unknown, key)
+		}
+	}
+	sort.Strings(unknown)
+
+	errs := make([]error, 0, len(unknown))
+	for _, key := range unknown {
+		errs = append(errs, fmt.Errorf("unknown key %s.%s", prefix, key))
+	}
+	return errs
+}
@@ -0,0 +1,105 @@
+package spec
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadSpecFile(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    SpecFile
+		wantErr []string
+	}{
+		{
+			name: "spec file",
+			content: `{
+				"name": "hello-api",
+				"module": "github.com/example/hello-api",
+				"http_port": 9090,
+				"template_dir": "packs/ours",
+				"vars": {"shutdown_timeout_seconds": 15, "debug": true, "team": "platform"},
+				"force": true,
+				"on_conflict": "skip"
+			}`,
+			want: SpecFile{
+				Name:        "hello-api",
+				Module:      "github.com/example/hello-api",
+				HTTPPort:    9090,
+				TemplateDir: "packs/ours",
+				Vars:        map[string]string{"shutdown_timeout_seconds": "15", "debug": "true", "team": "platform"},
+				Force:       true,
+				OnConflict:  "skip",
+			},
+		},
+		{
+			name: "marker",
+			content: `{
+				"tool": "gokit-scaffold",
+				"version": "0.1.0",
+				"template_pack": "service-http",
+				"spec": {"name": "old-svc", "module": "github.com/example/old-svc", "http_port": 8081},
+				"files": {"go.mod": "00"}
+			}`,
+			want: SpecFile{
+				Name:         "old-svc",
+				Module:       "github.com/example/old-svc",
+				HTTPPort:     8081,
+				Features:     []string{},
+				TemplatePack: "service-http",
+			},
+		},
+		{
+			name:    "unknown keys",
+			content: `{"name": "hello-api", "htp_port": 1, "feature": ["metrics"]}`,
+			wantErr: []string{"unknown key $.feature", "unknown key $.htp_port"},
+		},
+		{
+			name:    "unknown marker spec key",
+			content: `{"tool": "gokit-scaffold", "spec": {"name": "x", "port": 1}}`,
+			wantErr: []string{"unknown key $.spec.port"},
+		},
+		{
+			name:    "non-scalar variable",
+			content: `{"vars": {"tags": ["a"]}}`,
+			wantErr: []string{"$.vars.tags"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := t.TempDir()
+			path := filepath.Join(dir, "service.json")
+			if err := os.WriteFile(path, []byte(tt.content), 0o644); err != nil {
+				t.Fatalf("write spec file: %v", err)
+			}
+
+			got, err := ReadSpecFile(path)
+			if len(tt.wantErr) > 0 {
+				if err == nil {
+					t.Fatalf("expected error")
+				}
+				for _, want := range tt.wantErr {
+					if !strings.Contains(err.Error(), want) {
+						t.Fatalf("error %q does not mention %q", err, want)
+					}
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("read spec file: %v", err)
+			}
+
+			if tt.want.TemplateDir != "" {
+				tt.want.TemplateDir = filepath.Join(dir, tt.want.TemplateDir)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("got %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,123 @@
+package ui
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var ErrNoInput = errors.New("input ended before all questions were answered")
+
+// Prompter asks questions on out and reads one answer per line from in.
+type Prompter struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func NewPrompter(in io.Reader, out io.Writer) *Prompter {
+	return &Prompter{in: bufio.NewReader(in), out: out}
+}
+
+// IsTerminal reports whether f is an interactive terminal rather than a pipe
+// or a file.
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// Say prints an informational line between questions.
+func (p *Prompter) Say(format string, args ...any) {
+	fmt.Fprintf(p.out, format+"\n", args...)
+}
+
+// Ask prompts for a value until validate accepts it. An empty answer selects
+// def when there is one. Validation errors are shown and the question repeated.
+func (p *Prompter) Ask(label, def string, validate func(string) error) (string, error) {
+	for {
+		if def != "" {
+			fmt.Fprintf(p.out, "%s [%s]: ", label, def)
+		} else {
+			fmt.Fprintf(p.out, "%s: ", label)
+		}
+
+		answer, err := p.readLine()
+		if err != nil {
+			return "", err
+		}
+		if answer == "" {
+			answer = def
+		}
+		if answer == "" {
+			fmt.Fprintln(p.out, "  a value is required")
+			continue
+		}
+		if validate != nil {
+			if err := validate(answer); err != nil {
+				fmt.Fprintf(p.out, "  %q %v\n", answer, err)
+				continue
+			}
+		}
+		return answer, nil
+	}
+}
+
+// AskList prompts for a comma-separated list. An empty answer selects def;
+// "-" selects nothing.
+func (p *Prompter) AskList(label string, def []string, validate func(string) error) ([]string, error) {
+	shown := strings.Join(def, ",")
+	if shown == "" {
+		shown = "-"
+	}
+	for {
+		fmt.Fprintf(p.out, "%s [%s]: ", label, shown)
+
+		answer, err := p.readLine()
+		if err != nil {
+			return nil, err
+		}
+		switch answer {
+		case "":
+			return def, nil
+		case "-":
+			return []string{}, nil
+		}
+
+		var items []string
+		var invalid error
+		for _, item := range strings.Split(answer, ",") {
+			item = strings.TrimSpace(item)
+			if item == "" {
+				continue
+			}
+			if validate != nil {
+				if err := validate(item); err != nil {
+					invalid = fmt.Errorf("%q %v", item, err)
+					break
+				}
+			}
+			items = append(items, item)
+		}
+		if invalid != nil {
+			fmt.Fprintf(p.out, "  %v\n", invalid)
+			continue
+		}
+		return items, nil
+	}
+}
+
+func (p *Prompter) readLine() (string, error) {
+	line, err := p.in.ReadString('\n')
+	if err != nil && (!errors.Is(err, io.EOF) || line == "") {
+		if errors.Is(err, io.EOF) {
+			return "", ErrNoInput
+		}
+		return "", fmt.Errorf("read answer: %w", err)
+	}
+	return strings.TrimSpace(line), nil
+}
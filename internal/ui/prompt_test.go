@@ -0,0 +1,83 @@
+package ui
+
+import (
+	"bytes"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestPrompterAsk(t *testing.T) {
+	notBad := func(answer string) error {
+		if answer == "bad" {
+			return errors.New("must not be bad")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name    string
+		input   string
+		def     string
+		want    string
+		wantOut string
+		wantErr error
+	}{
+		{name: "answer", input: "good\n", want: "good"},
+		{name: "default", input: "\n", def: "fallback", want: "fallback", wantOut: "[fallback]"},
+		{name: "last line without newline", input: "good", want: "good"},
+		{name: "re-prompt on invalid", input: "bad\ngood\n", want: "good", wantOut: `"bad" must not be bad`},
+		{name: "re-prompt on empty", input: "\ngood\n", want: "good", wantOut: "a value is required"},
+		{name: "input ends", input: "bad\n", wantErr: ErrNoInput},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := NewPrompter(strings.NewReader(tt.input), &out).Ask("Name", tt.def, notBad)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("error = %v, want %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Fatalf("answer = %q, want %q", got, tt.want)
+			}
+			if !strings.Contains(out.String(), tt.wantOut) {
+				t.Fatalf("output %q does not contain %q", out.String(), tt.wantOut)
+			}
+		})
+	}
+}
+
+func TestPrompterAskList(t *testing.T) {
+	known := func(answer string) error {
+		if answer != "metrics" && answer != "pprof" {
+			return errors.New("is unknown")
+		}
+		return nil
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{name: "default", input: "\n", want: []string{"metrics"}},
+		{name: "none", input: "-\n", want: []string{}},
+		{name: "list", input: " pprof , metrics\n", want: []string{"pprof", "metrics"}},
+		{name: "re-prompt on unknown", input: "graphql\npprof\n", want: []string{"pprof"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var out bytes.Buffer
+			got, err := NewPrompter(strings.NewReader(tt.input), &out).AskList("Features", []string{"metrics"}, known)
+			if err != nil {
+				t.Fatalf("ask list: %v", err)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Fatalf("answer = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
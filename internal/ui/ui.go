@@ -9,10 +9,21 @@ func PrintRootUsage(tool string) {
 	fmt.Fprintf(os.Stderr, "Usage: %s <command> [flags]\n", tool)
 	fmt.Fprintln(os.Stderr, "Commands:")
 	fmt.Fprintln(os.Stderr, "  new       Generate a new project scaffold")
+	fmt.Fprintln(os.Stderr, "  init      Add the scaffold to an existing Go module")
 	fmt.Fprintln(os.Stderr, "  validate  Validate an existing scaffold")
+	fmt.Fprintln(os.Stderr, "  upgrade   Merge current template changes into an existing scaffold")
+	fmt.Fprintln(os.Stderr, "  render    Render one generated file to stdout")
+	fmt.Fprintln(os.Stderr, "  packs     List and inspect template packs")
 	fmt.Fprintln(os.Stderr, "  print     Print embedded template pack details")
 }
 
+func PrintPacksUsage(tool string) {
+	fmt.Fprintf(os.Stderr, "Usage: %s packs <command> [flags]\n", tool)
+	fmt.Fprintln(os.Stderr, "Commands:")
+	fmt.Fprintln(os.Stderr, "  list [--dir path]         List the embedded packs and those under --dir")
+	fmt.Fprintln(os.Stderr, "  show [--dir path] <pack>  Show a pack's variables, features and outputs")
+}
+
 func PrintError(err error) {
 	fmt.Fprintf(os.Stderr, "error: %v\n", err)
 }
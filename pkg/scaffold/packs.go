@@ -0,0 +1,104 @@
+package scaffold
+
+import (
+	"github.com/ridzuwary/gokit-scaffold/internal/generator"
+)
+
+// PackInfo describes a template pack and the knobs it offers.
+type PackInfo struct {
+	Name        string
+	Description string
+	Version     string
+	// Origin is "embedded" or the absolute directory of a local pack.
+	Origin string
+	// Layers lists the packs this one extends, base first and ending with Name.
+	Layers    []string
+	Variables []Variable
+	Features  []Feature
+	// Outputs lists the output paths of every entry as written in the
+	// manifest, so templated paths are not resolved.
+	Outputs []string
+}
+
+type Variable struct {
+	Name string
+	// Type is "string", "int" or "bool".
+	Type string
+	// Default is in the textual form Spec.Vars takes; HasDefault is false for
+	// variables that must be set.
+	Default     string
+	HasDefault  bool
+	Description string
+}
+
+type Feature struct {
+	Name        string
+	Description string
+	Default     bool
+	// Outputs are the files only generated when the feature is enabled.
+	Outputs []string
+}
+
+// Packs lists the embedded packs, then the pack directories under searchDir
+// when it is not empty.
+func Packs(searchDir string) ([]PackInfo, error) {
+	packs, err := generator.ListPacks(searchDir)
+	if err != nil {
+		return nil, err
+	}
+	infos := make([]PackInfo, 0, len(packs))
+	for _, pack := range packs {
+		info, err := packInfo(pack)
+		if err != nil {
+			return nil, err
+		}
+		infos = append(infos, info)
+	}
+	return infos, nil
+}
+
+// LookupPack finds a pack by embedded name, by directory path, or by name
+// under searchDir.
+func LookupPack(ref, searchDir string) (PackInfo, error) {
+	pack, err := generator.LoadPack(ref, searchDir)
+	if err != nil {
+		return PackInfo{}, err
+	}
+	return packInfo(pack)
+}
+
+func packInfo(pack generator.Pack) (PackInfo, error) {
+	info := PackInfo{
+		Name:        pack.Name,
+		Description: pack.Description,
+		Version:     pack.Version,
+		Origin:      pack.Origin,
+		Layers:      append([]string(nil), pack.Layers...),
+	}
+	for _, variable := range pack.Variables {
+		def, err := variable.DefaultString()
+		if err != nil {
+			return PackInfo{}, err
+		}
+		info.Variables = append(info.Variables, Variable{
+			Name:        variable.Name,
+			Type:        variable.Type,
+			Default:     def,
+			HasDefault:  variable.Default != nil,
+			Description: variable.Description,
+		})
+	}
+	outputs := pack.FeatureOutputs()
+	for _, feature := range pack.Features {
+		info.Features = append(info.Features, Feature{
+			Name:        feature.Name,
+			Description: feature.Description,
+			Default:     feature.Default,
+			Outputs:     outputs[feature.Name],
+		})
+	}
+	for _, entry := range pack.Entries {
+		info.Outputs = append(info.Outputs, entry.OutputPath)
+	}
+	return info, nil
+}
@@ -0,0 +1,325 @@
+// Package scaffold is the importable API of gokit-scaffold. It generates the
+// same trees as the CLI, with the same validation, staging and rollback. Its
+// types are its own, so they stay stable as the tool's internals change.
+package scaffold
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+
+	"github.com/ridzuwary/gokit-scaffold/internal/generator"
+	"github.com/ridzuwary/gokit-scaffold/internal/outfs"
+	"github.com/ridzuwary/gokit-scaffold/internal/spec"
+	"github.com/ridzuwary/gokit-scaffold/templates"
+)
+
+// Version is the tool version recorded in the markers this package writes.
+const Version = spec.ToolVersion
+
+// DefaultPack is the embedded template pack used when Spec.TemplateDir is
+// empty.
+const DefaultPack = templates.DefaultPack
+
+// Spec describes the project to generate, as the flags of `new` do.
+type Spec struct {
+	Name   string
+	Module string
+	// Dir is the target directory. It must be empty or missing unless
+	// Options.Force is set. It is ignored when Options.Output is set.
+	Dir      string
+	HTTPPort int
+	// GoVersion is the go.mod `go` version, such as "1.22"; empty means the Go
+	// version this package was built with.
+	GoVersion string
+	// TemplateDir loads the pack from a directory instead of DefaultPack.
+	TemplateDir string
+	Vars        map[string]string
+	// Features turns pack features on or off; unlisted ones keep their default.
+	Features map[string]bool
+}
+
+// ConflictPolicy decides what happens to files that already exist in a
+// non-empty target directory.
+type ConflictPolicy string
+
+const (
+	ConflictFail      ConflictPolicy = "fail"
+	ConflictSkip      ConflictPolicy = "skip"
+	ConflictOverwrite ConflictPolicy = "overwrite"
+	ConflictBackup    ConflictPolicy = "backup"
+)
+
+type Options struct {
+	// Force allows generating into a non-empty directory, resolving each
+	// existing file per OnConflict (default ConflictFail).
+	Force      bool
+	OnConflict ConflictPolicy
+	// Verify type-checks the generated Go packages offline before anything
+	// is written; failures are returned as a *TypeCheckError.
+	Verify bool
+	// Output, when set, receives the tree instead of Spec.Dir. Like Spec.Dir
+	// it must be empty unless Force is set; conflicts are then resolved
+	// against what Output already holds.
+	Output OutputFS
+}
+
+// OutputFS is a writable file system Generate can write into. Names are
+// slash-separated and relative to its root, as with fs.FS. MemFS, DirFS and
+// OpenRootFS return implementations.
+type OutputFS interface {
+	fs.FS
+	Lstat(name string) (fs.FileInfo, error)
+	MkdirAll(name string, perm fs.FileMode) error
+	// WriteFile creates or replaces name; readers never see a partial write.
+	WriteFile(name string, data []byte, perm fs.FileMode) error
+	Rename(oldname, newname string) error
+	Remove(name string) error
+	RemoveAll(name string) error
+}
+
+// MemFS returns an empty OutputFS held in memory.
+func MemFS() OutputFS {
+	return outfs.NewMem()
+}
+
+// DirFS returns an OutputFS for the directory dir on disk.
+func DirFS(dir string) OutputFS {
+	return outfs.Dir(dir)
+}
+
+// OpenRootFS returns an OutputFS for the existing directory dir that refuses
+// to reach outside it, even through symbolic links. Call close when done.
+func OpenRootFS(dir string) (fsys OutputFS, close func() error, err error) {
+	root, err := outfs.OpenRoot(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+	return root, root.Close, nil
+}
+
+// File is one planned output.
+type File struct {
+	Path     string
+	Template string
+	Content  []byte
+	Mode     fs.FileMode
+	SHA256   string
+}
+
+// Plan is what Generate would write.
+type Plan struct {
+	Dir   string
+	Pack  string
+	Files []File
+}
+
+type FileAction string
+
+const (
+	ActionCreated     FileAction = "created"
+	ActionSkipped     FileAction = "skipped"
+	ActionOverwritten FileAction = "overwritten"
+	ActionBackedUp    FileAction = "backed up"
+)
+
+type FileResult struct {
+	Path   string
+	Action FileAction
+	// BackupPath is set for ActionBackedUp.
+	BackupPath string
+}
+
+// Result reports what Generate did to every file.
+type Result struct {
+	Dir   string
+	Files []FileResult
+}
+
+// TypeError is a problem found by type-checking a generated Go file.
+type TypeError struct {
+	Path     string
+	Template string
+	Line     int
+	Column   int
+	Msg      string
+}
+
+func (e TypeError) String() string {
+	return generator.TypeError{OutputPath: e.Path, TemplatePath: e.Template, Line: e.Line, Column: e.Column, Msg: e.Msg}.String()
+}
+
+type TypeCheckError struct {
+	Errors []TypeError
+}
+
+func (e *TypeCheckError) Error() string {
+	return (&generator.TypeCheckError{Errors: internalTypeErrors(e.Errors)}).Error()
+}
+
+// Validate checks s as `new` does before generating, including the state of
+// the target: opts.Output when set, s.Dir otherwise.
+func (s Spec) Validate(opts Options) error {
+	project, err := s.project(opts)
+	if err != nil {
+		return err
+	}
+	return validate(project, opts)
+}
+
+func validate(project spec.ProjectSpec, opts Options) error {
+	if opts.Output == nil {
+		return project.Validate()
+	}
+	if err := project.ValidateSettings(); err != nil {
+		return err
+	}
+	if opts.Force {
+		return nil
+	}
+	entries, err := fs.ReadDir(opts.Output, ".")
+	if err != nil {
+		return fmt.Errorf("read output: %w", err)
+	}
+	if len(entries) > 0 {
+		return errors.New("output is not empty (set Force to write into it)")
+	}
+	return nil
+}
+
+// PlanFiles validates s and renders every output in memory without writing.
+// The context is checked between stages; a stage already running is not
+// interrupted.
+func PlanFiles(ctx context.Context, s Spec, opts Options) (Plan, error) {
+	plan, err := plan(ctx, s, opts)
+	if err != nil {
+		return Plan{}, err
+	}
+
+	out := Plan{
+		Dir:   plan.Dir,
+		Pack:  plan.TemplatePack,
+		Files: make([]File, 0, len(plan.Files)),
+	}
+	for _, file := range plan.Files {
+		mode := file.Mode
+		if mode == 0 {
+			mode = spec.DefaultFileMode
+		}
+		out.Files = append(out.Files, File{
+			Path:     file.OutputPath,
+			Template: file.TemplatePath,
+			Content:  file.Content,
+			Mode:     mode,
+			SHA256:   file.SHA256(),
+		})
+	}
+	return out, nil
+}
+
+// Generate validates s, renders it and writes the tree to s.Dir or
+// opts.Output, staging it so that a failure leaves the target as it was. The
+// context is checked between stages and before anything is written.
+func Generate(ctx context.Context, s Spec, opts Options) (Result, error) {
+	plan, err := plan(ctx, s, opts)
+	if err != nil {
+		return Result{}, err
+	}
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	var report generator.Report
+	if opts.Output != nil {
+		report, err = generator.WriteTo(opts.Output, plan, conflictPolicy(opts))
+	} else {
+		report, err = generator.Write(plan, conflictPolicy(opts))
+	}
+	if err != nil {
+		return Result{}, err
+	}
+	result := Result{Dir: report.Dir, Files: make([]FileResult, 0, len(report.Files))}
+	for _, file := range report.Files {
+		result.Files = append(result.Files, FileResult{
+			Path:       file.OutputPath,
+			Action:     FileAction(file.Action),
+			BackupPath: file.BackupPath,
+		})
+	}
+	return result, nil
+}
+
+func plan(ctx context.Context, s Spec, opts Options) (generator.RenderPlan, error) {
+	if err := ctx.Err(); err != nil {
+		return generator.RenderPlan{}, err
+	}
+	project, err := s.project(opts)
+	if err != nil {
+		return generator.RenderPlan{}, err
+	}
+	if err := validate(project, opts); err != nil {
+		return generator.RenderPlan{}, err
+	}
+
+	plan, err := generator.Plan(project, Version)
+	if err != nil {
+		return generator.RenderPlan{}, err
+	}
+	if opts.Verify {
+		if err := ctx.Err(); err != nil {
+			return generator.RenderPlan{}, err
+		}
+		if err := generator.Verify(plan); err != nil {
+			var typeErr *generator.TypeCheckError
+			if errors.As(err, &typeErr) {
+				return generator.RenderPlan{}, &TypeCheckError{Errors: typeErrors(typeErr.Errors)}
+			}
+			return generator.RenderPlan{}, err
+		}
+	}
+	return plan, nil
+}
+
+func (s Spec) project(opts Options) (spec.ProjectSpec, error) {
+	if _, err := generator.ParseConflictPolicy(string(conflictPolicy(opts))); err != nil {
+		return spec.ProjectSpec{}, err
+	}
+	if opts.OnConflict != "" && opts.OnConflict != ConflictFail && !opts.Force {
+		return spec.ProjectSpec{}, fmt.Errorf("conflict policy %s requires Force", opts.OnConflict)
+	}
+	return spec.ProjectSpec{
+		Name:        s.Name,
+		Module:      s.Module,
+		Dir:         s.Dir,
+		HTTPPort:    s.HTTPPort,
+		GoVersion:   s.GoVersion,
+		Force:       opts.Force,
+		TemplateDir: s.TemplateDir,
+		Vars:        s.Vars,
+		Features:    s.Features,
+	}, nil
+}
+
+func conflictPolicy(opts Options) generator.ConflictPolicy {
+	if opts.OnConflict == "" {
+		return generator.ConflictFail
+	}
+	return generator.ConflictPolicy(opts.OnConflict)
+}
+
+func typeErrors(errs []generator.TypeError) []TypeError {
+	out := make([]TypeError, 0, len(errs))
+	for _, e := range errs {
+		out = append(out, TypeError{Path: e.OutputPath, Template: e.TemplatePath, Line: e.Line, Column: e.Column, Msg: e.Msg})
+	}
+	return out
+}
+
+func internalTypeErrors(errs []TypeError) []generator.TypeError {
+	out := make([]generator.TypeError, 0, len(errs))
+	for _, e := range errs {
+		out = append(out, generator.TypeError{OutputPath: e.Path, TemplatePath: e.Template, Line: e.Line, Column: e.Column, Msg: e.Msg})
+	}
+	return out
+}
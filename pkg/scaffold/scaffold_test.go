@@ -0,0 +1,218 @@
+package scaffold
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateAndValidate(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "hello-api")
+	s := Spec{
+		Name:      "hello-api",
+		Module:    "github.com/example/hello-api",
+		Dir:       dir,
+		HTTPPort:  8080,
+		GoVersion: "1.22",
+		Features:  map[string]bool{"metrics": true},
+	}
+
+	plan, err := PlanFiles(context.Background(), s, Options{})
+	if err != nil {
+		t.Fatalf("plan: %v", err)
+	}
+	if plan.Pack != DefaultPack || len(plan.Files) == 0 {
+		t.Fatalf("unexpected plan: %+v", plan)
+	}
+	if _, err := os.Stat(dir); !os.IsNotExist(err) {
+		t.Fatalf("planning should not write anything: %v", err)
+	}
+
+	result, err := Generate(context.Background(), s, Options{})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	if len(result.Files) != len(plan.Files) {
+		t.Fatalf("wrote %d files, planned %d", len(result.Files), len(plan.Files))
+	}
+	for _, file := range result.Files {
+		if file.Action != ActionCreated {
+			t.Fatalf("%s: action %s, want %s", file.Path, file.Action, ActionCreated)
+		}
+	}
+
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# edited\n"), 0o644); err != nil {
+		t.Fatalf("edit README.md: %v", err)
+	}
+	v, err := Validate(context.Background(), dir, ValidateOptions{})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	if !v.Valid() {
+		t.Fatalf("expected a valid scaffold: %+v", v)
+	}
+	modified := 0
+	for _, drift := range v.Drift {
+		if drift.State == FileModified {
+			modified++
+			if drift.Path != "README.md" {
+				t.Fatalf("unexpected modified file %s", drift.Path)
+			}
+		}
+	}
+	if modified != 1 {
+		t.Fatalf("expected README.md to be reported as modified: %+v", v.Drift)
+	}
+
+	if _, err := Generate(context.Background(), s, Options{}); err == nil {
+		t.Fatalf("expected generating into a non-empty directory without Force to fail")
+	}
+	if err := os.Remove(filepath.Join(s.Dir, "go.mod")); err != nil {
+		t.Fatalf("remove go.mod: %v", err)
+	}
+	v, err = Validate(context.Background(), s.Dir, ValidateOptions{})
+	if err != nil {
+		t.Fatalf("validate: %v", err)
+	}
+	deleted := false
+	for _, drift := range v.Drift {
+		deleted = deleted || (drift.Path == "go.mod" && drift.State == FileDeleted)
+	}
+	if v.Valid() || !deleted {
+		t.Fatalf("expected a missing go.mod to be a problem and reported as deleted: %+v", v)
+	}
+	if len(v.Problems) != 1 || v.Problems[0].Kind != ProblemMissingFile || v.Problems[0].Path != "go.mod" {
+		t.Fatalf("expected one missing file problem for go.mod, got %+v", v.Problems)
+	}
+	v, err = Validate(context.Background(), t.TempDir(), ValidateOptions{})
+	if err != nil {
+		t.Fatalf("validate empty dir: %v", err)
+	}
+	if v.Valid() || len(v.Problems) == 0 || v.Problems[0].Kind != ProblemMarker {
+		t.Fatalf("expected an empty directory to be invalid for its marker: %+v", v)
+	}
+
+	notDir := filepath.Join(t.TempDir(), "file")
+	if err := os.WriteFile(notDir, nil, 0o644); err != nil {
+		t.Fatalf("write %s: %v", notDir, err)
+	}
+	if err := os.Mkdir(filepath.Join(s.Dir, "go.mod"), 0o755); err != nil {
+		t.Fatalf("mkdir go.mod: %v", err)
+	}
+	for _, tt := range []struct {
+		dir  string
+		kind ProblemKind
+	}{
+		{dir: notDir, kind: ProblemDir},
+		{dir: s.Dir, kind: ProblemFile},
+	} {
+		v, err = Validate(context.Background(), tt.dir, ValidateOptions{})
+		if err != nil {
+			t.Fatalf("validate %s: %v", tt.dir, err)
+		}
+		if len(v.Problems) != 1 || v.Problems[0].Kind != tt.kind {
+			t.Fatalf("%s: expected one %s problem, got %+v", tt.dir, tt.kind, v.Problems)
+		}
+	}
+}
+
+func TestGenerateOptions(t *testing.T) {
+	s := Spec{
+		Name:     "hello-api",
+		Module:   "github.com/example/hello-api",
+		Dir:      filepath.Join(t.TempDir(), "hello-api"),
+		HTTPPort: 8080,
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Generate(ctx, s, Options{}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+	if _, err := os.Stat(s.Dir); !os.IsNotExist(err) {
+		t.Fatalf("a cancelled generate should not write anything: %v", err)
+	}
+
+	if err := s.Validate(Options{OnConflict: ConflictSkip}); err == nil {
+		t.Fatalf("expected a conflict policy without Force to be rejected")
+	}
+	if err := s.Validate(Options{Force: true, OnConflict: "merge"}); err == nil {
+		t.Fatalf("expected an unknown conflict policy to be rejected")
+	}
+}
+
+func TestGenerateIntoOutput(t *testing.T) {
+	s := Spec{
+		Name:      "hello-api",
+		Module:    "github.com/example/hello-api",
+		HTTPPort:  8080,
+		GoVersion: "1.22",
+	}
+	out := MemFS()
+
+	result, err := Generate(context.Background(), s, Options{Output: out})
+	if err != nil {
+		t.Fatalf("generate: %v", err)
+	}
+	for _, file := range result.Files {
+		if _, err := fs.Stat(out, file.Path); err != nil {
+			t.Fatalf("%s was not written to the output: %v", file.Path, err)
+		}
+	}
+	gomod, err := fs.ReadFile(out, "go.mod")
+	if err != nil || !strings.Contains(string(gomod), "module github.com/example/hello-api") {
+		t.Fatalf("unexpected go.mod %q: %v", gomod, err)
+	}
+
+	before, err := fs.ReadFile(out, "go.mod")
+	if err != nil {
+		t.Fatalf("read go.mod: %v", err)
+	}
+	if err := out.WriteFile("go.mod", []byte("module local\n"), 0o644); err != nil {
+		t.Fatalf("edit go.mod: %v", err)
+	}
+	if _, err := Generate(context.Background(), s, Options{Output: out}); err == nil || !strings.Contains(err.Error(), "not empty") {
+		t.Fatalf("expected a non-empty output to need Force, got %v", err)
+	}
+	if _, err := Generate(context.Background(), s, Options{Output: out, Force: true}); err == nil {
+		t.Fatalf("expected existing files in the output to conflict")
+	}
+	if _, err := Generate(context.Background(), s, Options{Output: out, Force: true, OnConflict: ConflictOverwrite}); err != nil {
+		t.Fatalf("generate with Force: %v", err)
+	}
+	if got, _ := fs.ReadFile(out, "go.mod"); !bytes.Equal(got, before) {
+		t.Fatalf("expected go.mod to be overwritten, got %q", got)
+	}
+}
+
+func TestPacks(t *testing.T) {
+	packs, err := Packs("")
+	if err != nil {
+		t.Fatalf("packs: %v", err)
+	}
+	if len(packs) == 0 || packs[0].Name != DefaultPack || packs[0].Origin != "embedded" {
+		t.Fatalf("unexpected packs: %+v", packs)
+	}
+
+	pack, err := LookupPack(DefaultPack, "")
+	if err != nil {
+		t.Fatalf("lookup: %v", err)
+	}
+	var metrics *Feature
+	for i := range pack.Features {
+		if pack.Features[i].Name == "metrics" {
+			metrics = &pack.Features[i]
+		}
+	}
+	if metrics == nil || len(metrics.Outputs) != 1 || metrics.Outputs[0] != "internal/httpserver/metrics.go" {
+		t.Fatalf("unexpected metrics feature: %+v", metrics)
+	}
+	if len(pack.Variables) != 1 || pack.Variables[0].Default != "5" || !pack.Variables[0].HasDefault {
+		t.Fatalf("unexpected variables: %+v", pack.Variables)
+	}
+}
@@ -0,0 +1,128 @@
+package scaffold
+
+import (
+	"context"
+	"errors"
+	"io/fs"
+
+	"github.com/ridzuwary/gokit-scaffold/internal/generator"
+	"github.com/ridzuwary/gokit-scaffold/internal/spec"
+)
+
+type FileState string
+
+const (
+	FileUntouched FileState = "untouched"
+	FileModified  FileState = "modified"
+	FileDeleted   FileState = "deleted"
+)
+
+// Drift is the state of one generated file compared with the marker.
+type Drift struct {
+	Path  string
+	State FileState
+	// WantMode is the mode the file was generated with and Mode its current
+	// one; they only differ when the executable bits were changed.
+	WantMode fs.FileMode
+	Mode     fs.FileMode
+}
+
+type ProblemKind string
+
+const (
+	// ProblemDir means the directory itself cannot be validated.
+	ProblemDir ProblemKind = "directory"
+	// ProblemMarker means the marker is missing, unreadable or invalid.
+	ProblemMarker      ProblemKind = "marker"
+	ProblemMissingFile ProblemKind = "missing file"
+	// ProblemFile means a required file exists but cannot be used.
+	ProblemFile ProblemKind = "file"
+)
+
+// Problem is a reason a directory is not a valid scaffold. Path is the
+// directory for ProblemDir and relative to it otherwise.
+type Problem struct {
+	Kind    ProblemKind
+	Path    string
+	Message string
+	err     error
+}
+
+func (p Problem) Error() string {
+	return p.Message
+}
+
+// Unwrap returns the error the problem was found with, so that errors.Is and
+// errors.As reach its cause, such as a failed stat.
+func (p Problem) Unwrap() error {
+	return p.err
+}
+
+type ValidateOptions struct {
+	// TypeCheck also type-checks the scaffold's Go packages offline.
+	TypeCheck bool
+}
+
+// Validation is the outcome of Validate. Problems are the reasons the
+// directory is not a valid scaffold; drift is collected whenever the marker
+// can be read, type errors only when there are no problems.
+type Validation struct {
+	Problems   []Problem
+	Drift      []Drift
+	TypeErrors []TypeError
+}
+
+// Valid reports whether the directory is a scaffold with a valid marker, its
+// required files present and, if checked, Go code that type-checks. Drift does
+// not make a scaffold invalid.
+func (v Validation) Valid() bool {
+	return len(v.Problems) == 0 && len(v.TypeErrors) == 0
+}
+
+// Validate checks dir as `gokit-scaffold validate` does. The returned error is
+// for failures to perform the checks, not for what they found.
+func Validate(ctx context.Context, dir string, opts ValidateOptions) (Validation, error) {
+	if err := ctx.Err(); err != nil {
+		return Validation{}, err
+	}
+
+	var v Validation
+	for _, err := range spec.ValidateScaffoldDir(dir) {
+		problem := Problem{Kind: ProblemFile, Message: err.Error(), err: err}
+		var specProblem *spec.Problem
+		if errors.As(err, &specProblem) {
+			problem.Kind = ProblemKind(specProblem.Kind)
+			problem.Path = specProblem.Path
+			problem.err = specProblem.Err
+		}
+		v.Problems = append(v.Problems, problem)
+	}
+
+	drift, err := spec.ScaffoldDrift(dir)
+	if err != nil && len(v.Problems) == 0 {
+		return Validation{}, err
+	}
+	for _, file := range drift {
+		v.Drift = append(v.Drift, Drift{
+			Path:     file.Path,
+			State:    FileState(file.State),
+			WantMode: file.WantMode,
+			Mode:     file.Mode,
+		})
+	}
+	if len(v.Problems) > 0 {
+		return v, nil
+	}
+
+	if opts.TypeCheck {
+		if err := ctx.Err(); err != nil {
+			return Validation{}, err
+		}
+		typeErrs, err := generator.TypeCheckDir(dir)
+		if err != nil {
+			return Validation{}, err
+		}
+		v.TypeErrors = typeErrors(typeErrs)
+	}
+	return v, nil
+}
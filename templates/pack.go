@@ -0,0 +1,159 @@
+package templates
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"math"
+	"path"
+	"sort"
+	"strconv"
+)
+
+const PackManifestFile = "pack.json"
+
+const DefaultPack = "service-http"
+
+const (
+	VariableString = "string"
+	VariableInt    = "int"
+	VariableBool   = "bool"
+)
+
+type PackManifest struct {
+	Name        string         `json:"name,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Version     string         `json:"version,omitempty"`
+	Extends     string         `json:"extends,omitempty"`
+	Entries     []PackEntry    `json:"entries"`
+	Required    []string       `json:"required,omitempty"`
+	Variables   []PackVariable `json:"variables,omitempty"`
+	Features    []PackFeature  `json:"features,omitempty"`
+}
+
+type PackEntry struct {
+	Template string   `json:"template"`
+	Output   string   `json:"output"`
+	When     string   `json:"when,omitempty"`
+	Mode     string   `json:"mode,omitempty"`
+	Copy     bool     `json:"copy,omitempty"`
+	Delims   []string `json:"delims,omitempty"`
+	// Delete removes the entry with this output from the extended pack.
+	Delete bool `json:"delete,omitempty"`
+}
+
+type PackFeature struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Default     bool   `json:"default,omitempty"`
+}
+
+type PackVariable struct {
+	Name        string `json:"name"`
+	Type        string `json:"type"`
+	Default     any    `json:"default,omitempty"`
+	Description string `json:"description,omitempty"`
+}
+
+// DefaultString returns the variable's default in the same textual form a user
+// would pass on the command line, or "" when the variable has no default.
+func (v PackVariable) DefaultString() (string, error) {
+	switch value := v.Default.(type) {
+	case nil:
+		return "", nil
+	case string:
+		if v.Type == VariableString {
+			return value, nil
+		}
+	case bool:
+		if v.Type == VariableBool {
+			return strconv.FormatBool(value), nil
+		}
+	case float64:
+		if v.Type == VariableInt && value == math.Trunc(value) {
+			return strconv.FormatInt(int64(value), 10), nil
+		}
+	}
+	return "", fmt.Errorf("variable %s: default %v is not a valid %s", v.Name, v.Default, v.Type)
+}
+
+// ReadPackManifest decodes the pack.json at the root of dir in fsys. Unknown
+// fields are rejected so typos in a pack fail loudly instead of being ignored.
+func ReadPackManifest(fsys fs.FS, dir string) (PackManifest, error) {
+	var manifest PackManifest
+
+	file := path.Join(dir, PackManifestFile)
+	content, err := fs.ReadFile(fsys, file)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return manifest, fmt.Errorf("template pack is missing %s", PackManifestFile)
+		}
+		return manifest, fmt.Errorf("read %s: %w", PackManifestFile, err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(content))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&manifest); err != nil {
+		return manifest, fmt.Errorf("parse %s: %w", PackManifestFile, err)
+	}
+
+	for _, variable := range manifest.Variables {
+		switch variable.Type {
+		case VariableString, VariableInt, VariableBool:
+		default:
+			return manifest, fmt.Errorf("variable %s: unknown type %q (want string, int or bool)", variable.Name, variable.Type)
+		}
+		if _, err := variable.DefaultString(); err != nil {
+			return manifest, err
+		}
+	}
+
+	return manifest, nil
+}
+
+func EmbeddedPacks() []string {
+	entries, err := FS.ReadDir(".")
+	if err != nil {
+		return nil
+	}
+
+	var names []string
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		if _, err := fs.Stat(FS, path.Join(entry.Name(), PackManifestFile)); err == nil {
+			names = append(names, entry.Name())
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+func EmbeddedManifest(name string) (PackManifest, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return PackManifest{}, fmt.Errorf("unknown template pack: %s", name)
+	}
+	if _, err := fs.Stat(FS, path.Join(name, PackManifestFile)); err != nil {
+		return PackManifest{}, fmt.Errorf("unknown template pack: %s", name)
+	}
+
+	manifest, err := ReadPackManifest(FS, name)
+	if err != nil {
+		return PackManifest{}, fmt.Errorf("embedded template pack %s: %w", name, err)
+	}
+	if manifest.Name != name {
+		return PackManifest{}, fmt.Errorf("embedded template pack %s: %s declares name %q", name, PackManifestFile, manifest.Name)
+	}
+	return manifest, nil
+}
+
+func MustEmbeddedManifest(name string) PackManifest {
+	manifest, err := EmbeddedManifest(name)
+	if err != nil {
+		panic(err)
+	}
+	return manifest
+}
@@ -1,6 +1,45 @@
 package templates
 
-import "embed"
+import (
+	"embed"
+	"fmt"
+	"io/fs"
+	"path"
+	"strings"
+)
 
 //go:embed service-http/**
 var FS embed.FS
+
+// Releases holds a frozen copy of the template packs shipped with every
+// released tool version, so `upgrade` can re-render a scaffold's pristine base.
+//
+//go:embed all:releases
+var releases embed.FS
+
+func Release(version string) (fs.FS, error) {
+	if version == "" || version == "." || strings.Contains(version, "/") || !fs.ValidPath(version) {
+		return nil, fmt.Errorf("invalid release version %q", version)
+	}
+
+	dir := path.Join("releases", version)
+	if _, err := fs.Stat(releases, dir); err != nil {
+		return nil, fmt.Errorf("no pristine templates embedded for version %s", version)
+	}
+	return fs.Sub(releases, dir)
+}
+
+func ReleaseVersions() []string {
+	entries, err := releases.ReadDir("releases")
+	if err != nil {
+		return nil
+	}
+
+	versions := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if entry.IsDir() {
+			versions = append(versions, entry.Name())
+		}
+	}
+	return versions
+}
@@ -3,8 +3,8 @@ package httpserver
 import "net/http"
 
 func registerRoutes(mux *http.ServeMux) {
-	mux.HandleFunc("/healthz", healthHandler)
-	mux.HandleFunc("/readyz", readyHandler)
+	mux.HandleFunc("GET /healthz", healthHandler)
+	mux.HandleFunc("GET /readyz", readyHandler)
 }
 
 func healthHandler(w http.ResponseWriter, _ *http.Request) {